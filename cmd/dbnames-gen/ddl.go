@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/denisbdn/dbnames"
+)
+
+const header = "// Code generated by dbnames-gen. DO NOT EDIT.\n\n"
+
+/*
+генерирует структуры с тегами db по CREATE TABLE: для каждой таблицы константа
+DB<Name>Table с ее именем и структура DB<Name>. Тип столбца сохраняется в теге dbtype,
+первичный ключ, автоинкремент и значение по умолчанию в dbddl, параметры таблицы
+и составные индексы в методе TableOptions, поэтому BuildCreateTable по структуре
+выдает ту же таблицу
+*/
+func generateFromDDL(ddl string, pkg string) ([]byte, error) {
+	tables, err := dbnames.ParseCreateTables(ddl)
+	if err != nil {
+		return nil, err
+	}
+	if len(tables) == 0 {
+		return nil, fmt.Errorf("no CREATE TABLE found")
+	}
+	var body bytes.Buffer
+	useDBNames := false
+	for _, table := range tables {
+		name := "DB" + goName(table.Name)
+		fmt.Fprintf(&body, "const %sTable string = %q\n\n", name, table.Name)
+		fmt.Fprintf(&body, "type %s struct {\n", name)
+		tagged := make(map[string]bool)
+		for _, index := range table.Indexes {
+			if len(index.Columns) == 1 && index.Name == indexName(index.Columns[0], index.Unique) {
+				tagged[index.Name] = true
+			}
+		}
+		for _, column := range table.Columns {
+			goType, pkgUsed := columnGoType(column)
+			useDBNames = useDBNames || pkgUsed
+			options := make([]string, 0)
+			for _, pk := range table.PrimaryKey {
+				if pk == column.Name {
+					options = append(options, "pk")
+				}
+			}
+			if column.AutoIncrement {
+				options = append(options, "autoinc")
+			}
+			if column.Null && !strings.HasPrefix(goType, "*") {
+				options = append(options, "null")
+			}
+			for _, index := range table.Indexes {
+				if tagged[index.Name] && index.Columns[0] == column.Name {
+					if index.Unique {
+						options = append(options, "unique")
+					} else {
+						options = append(options, "index")
+					}
+				}
+			}
+			// запятые внутри кавычек и скобок TableFromStruct не делит, см dbddl default
+			if column.Default != nil && !(column.Null && strings.EqualFold(*column.Default, "NULL")) {
+				options = append(options, "default="+*column.Default)
+			}
+			tag := fmt.Sprintf(`db:%q dbtype:%q`, column.Name, column.Type)
+			if len(options) > 0 {
+				tag += fmt.Sprintf(` dbddl:%q`, strings.Join(options, ","))
+			}
+			fmt.Fprintf(&body, "\t%s %s `%s`\n", goName(column.Name), goType, tag)
+		}
+		body.WriteString("}\n\n")
+
+		indexes := make([]dbnames.Index, 0)
+		for _, index := range table.Indexes {
+			if !tagged[index.Name] {
+				indexes = append(indexes, index)
+			}
+		}
+		if len(table.Engine) > 0 || len(table.Charset) > 0 || len(table.Collate) > 0 || len(indexes) > 0 {
+			useDBNames = true
+			fmt.Fprintf(&body, "func (%s) TableOptions() dbnames.TableOptions {\n", name)
+			fmt.Fprintf(&body, "\treturn dbnames.TableOptions{\n")
+			fmt.Fprintf(&body, "\t\tEngine: %q,\n\t\tCharset: %q,\n\t\tCollate: %q,\n", table.Engine, table.Charset, table.Collate)
+			if len(indexes) > 0 {
+				fmt.Fprintf(&body, "\t\tIndexes: []dbnames.Index{\n")
+				for _, index := range indexes {
+					columns := make([]string, 0, len(index.Columns))
+					for _, column := range index.Columns {
+						columns = append(columns, strconv.Quote(column))
+					}
+					fmt.Fprintf(&body, "\t\t\t{Name: %q, Columns: []string{%s}, Unique: %t},\n", index.Name, strings.Join(columns, ", "), index.Unique)
+				}
+				fmt.Fprintf(&body, "\t\t},\n")
+			}
+			fmt.Fprintf(&body, "\t}\n}\n\n")
+		}
+	}
+	var code bytes.Buffer
+	code.WriteString(header)
+	fmt.Fprintf(&code, "package %s\n\n", pkg)
+	if useDBNames {
+		code.WriteString("import \"github.com/denisbdn/dbnames\"\n\n")
+	}
+	code.Write(body.Bytes())
+	return format.Source(code.Bytes())
+}
+
+func indexName(column string, unique bool) string {
+	if unique {
+		return "uniq_" + column
+	}
+	return "idx_" + column
+}
+
+/*
+тип поля по типу столбца, второе значение - нужен ли импорт dbnames
+*/
+func columnGoType(column dbnames.Column) (string, bool) {
+	lower := strings.ToLower(column.Type)
+	unsigned := strings.Contains(lower, "unsigned")
+	base := lower
+	if i := strings.IndexAny(base, "( "); i >= 0 {
+		base = base[:i]
+	}
+	goType := "string"
+	pkgUsed := false
+	switch base {
+	case "tinyint":
+		goType = "int8"
+	case "smallint", "year":
+		goType = "int16"
+	case "mediumint", "int", "integer":
+		goType = "int32"
+	case "bigint":
+		goType = "int64"
+	case "float":
+		goType = "float32"
+	case "double", "real", "decimal", "numeric":
+		goType = "float64"
+	case "date", "datetime", "timestamp":
+		goType = "dbnames.MYSQLDATETIME"
+		pkgUsed = true
+	}
+	if unsigned && strings.HasPrefix(goType, "int") {
+		goType = "u" + goType
+	}
+	if column.Null {
+		goType = "*" + goType
+	}
+	return goType, pkgUsed
+}
+
+/*
+имя в стиле go: user_id -> UserId, auth-type -> AuthType
+*/
+func goName(name string) string {
+	var sb strings.Builder
+	upper := true
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			sb.WriteRune(unicode.ToUpper(r))
+			upper = false
+		} else {
+			sb.WriteRune(r)
+		}
+	}
+	res := sb.String()
+	if len(res) == 0 || unicode.IsDigit(rune(res[0])) {
+		res = "X" + res
+	}
+	return res
+}
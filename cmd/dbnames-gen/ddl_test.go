@@ -0,0 +1,64 @@
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+const sessionDump = "DROP TABLE IF EXISTS `user_session`;\n" +
+	"CREATE TABLE `user_session` (\n" +
+	"  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,\n" +
+	"  `user_id` int(10) unsigned NOT NULL,\n" +
+	"  `token` varchar(64) NOT NULL,\n" +
+	"  `state` enum('new','used') NOT NULL DEFAULT 'new',\n" +
+	"  `expire` datetime DEFAULT NULL,\n" +
+	"  `score` double DEFAULT NULL,\n" +
+	"  `tags` set('a','b') NOT NULL DEFAULT 'a,b',\n" +
+	"  PRIMARY KEY (`id`),\n" +
+	"  UNIQUE KEY `uniq_token` (`token`),\n" +
+	"  KEY `idx_user_state` (`user_id`,`state`)\n" +
+	") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;\n"
+
+func TestGenerateFromDDL(t *testing.T) {
+	code, err := generateFromDDL(sessionDump, "models")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(code)
+	t.Log(src)
+	if _, err := parser.ParseFile(token.NewFileSet(), "models.go", code, 0); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"package models",
+		`const DBUserSessionTable string = "user_session"`,
+		"type DBUserSession struct {",
+		"`db:\"id\" dbtype:\"bigint(20) unsigned\" dbddl:\"pk,autoinc\"`",
+		"UserId uint32",
+		"`db:\"token\" dbtype:\"varchar(64)\" dbddl:\"unique\"`",
+		"`db:\"state\" dbtype:\"enum('new','used')\" dbddl:\"default='new'\"`",
+		"Expire *dbnames.MYSQLDATETIME",
+		"Score  *float64",
+		"`db:\"tags\" dbtype:\"set('a','b')\" dbddl:\"default='a,b'\"`",
+		`{Name: "idx_user_state", Columns: []string{"user_id", "state"}, Unique: false}`,
+	}
+	for _, e := range expected {
+		if !strings.Contains(src, e) {
+			t.Errorf("no %s in generated code", e)
+		}
+	}
+
+	if _, err := generateFromDDL("SELECT 1;", "models"); err == nil {
+		t.Errorf("ddl without tables accepted")
+	}
+}
+
+func TestGoName(t *testing.T) {
+	for name, expected := range map[string]string{"user_id": "UserId", "auth-type": "AuthType", "create": "Create", "2fa": "X2fa"} {
+		if res := goName(name); res != expected {
+			t.Errorf("goName(%s) = %s", name, res)
+		}
+	}
+}
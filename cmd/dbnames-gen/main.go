@@ -0,0 +1,67 @@
+/*
+dbnames-gen генерирует go код для пакета dbnames.
+
+Структуры по CREATE TABLE (вывод SHOW CREATE TABLE или mysqldump):
+
+	dbnames-gen -ddl schema.sql -package models -out models_gen.go
+
+Списки столбцов, типизированные столбцы (см dbnames.Col) и методы
+FillFromDBResult без рефлексии для структур из файла,
+результат пишется в <file>_dbnames.go (или <file>_dbnames_test.go для тестов):
+
+	//go:generate go run github.com/denisbdn/dbnames/cmd/dbnames-gen -src $GOFILE
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+func main() {
+	ddlFile := flag.String("ddl", "", "file with CREATE TABLE statements, - for stdin")
+	srcFile := flag.String("src", "", "go file with db tagged structs")
+	pkg := flag.String("package", os.Getenv("GOPACKAGE"), "package name of generated file")
+	out := flag.String("out", "", "output file, stdout if empty")
+	flag.Parse()
+
+	if len(*pkg) == 0 {
+		*pkg = "models"
+	}
+	var code []byte
+	var err error
+	switch {
+	case len(*ddlFile) > 0:
+		var ddl []byte
+		if *ddlFile == "-" {
+			ddl, err = io.ReadAll(os.Stdin)
+		} else {
+			ddl, err = os.ReadFile(*ddlFile)
+		}
+		if err == nil {
+			code, err = generateFromDDL(string(ddl), *pkg)
+		}
+	case len(*srcFile) > 0:
+		code, err = generateFromSource(*srcFile)
+		if len(*out) == 0 {
+			*out = generatedFileName(*srcFile)
+		}
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "dbnames-gen:", err)
+		os.Exit(1)
+	}
+	if len(*out) == 0 {
+		os.Stdout.Write(code)
+		return
+	}
+	if err := os.WriteFile(*out, code, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, "dbnames-gen:", err)
+		os.Exit(1)
+	}
+}
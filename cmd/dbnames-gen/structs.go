@@ -0,0 +1,394 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+/*
+поле структуры с тегом db для генерации
+*/
+type genField struct {
+	name     string
+	column   string
+	typeExpr string // тип как записан в исходнике, без звездочки
+	kind     string // string, int, uint, float, bool, fromstring, custom
+	bits     int
+	pointer  bool
+	imports  []string // импорты для типа поля в виде строк блока import
+}
+
+type genStruct struct {
+	name   string
+	table  string // значение константы <name>Table если она есть
+	fields []genField
+}
+
+/*
+пакет в котором лежит исходник: объявления типов и строковых констант,
+imports это импорты самого исходника по имени пакета (с учетом алиасов)
+*/
+type genPackage struct {
+	name    string
+	types   map[string]ast.Expr
+	consts  map[string]string
+	imports map[string]string
+	fset    *token.FileSet
+}
+
+/*
+генерирует для всех структур с тегами db из файла file константы со списком столбцов
+(как BuildFields) и метод FillFromDBResult без рефлексии. Типы полей ищутся во всех
+файлах пакета, для _test.go файлов учитываются и тестовые файлы
+*/
+func generateFromSource(file string) ([]byte, error) {
+	pkg, err := loadPackage(file)
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	src, err := parser.ParseFile(fset, file, nil, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	pkg.imports = fileImports(src)
+	structs := make([]genStruct, 0)
+	for _, decl := range src.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			typeSpec := spec.(*ast.TypeSpec)
+			st, ok := typeSpec.Type.(*ast.StructType)
+			if !ok || typeSpec.TypeParams != nil {
+				continue
+			}
+			res := genStruct{name: typeSpec.Name.Name, table: pkg.consts[typeSpec.Name.Name+"Table"]}
+			for _, field := range st.Fields.List {
+				if field.Tag == nil || len(field.Names) == 0 {
+					continue
+				}
+				tag, err := strconv.Unquote(field.Tag.Value)
+				if err != nil {
+					continue
+				}
+				column, ok := reflect.StructTag(tag).Lookup("db")
+				if !ok {
+					continue
+				}
+				for _, name := range field.Names {
+					if !name.IsExported() {
+						continue
+					}
+					res.fields = append(res.fields, pkg.field(name.Name, column, field.Type))
+				}
+			}
+			if len(res.fields) > 0 {
+				structs = append(structs, res)
+			}
+		}
+	}
+	if len(structs) == 0 {
+		return nil, fmt.Errorf("no structs with db tags in %s", file)
+	}
+	return pkg.generate(structs)
+}
+
+/*
+импорты файла по имени под которым пакет виден в коде: алиас или имя из пути,
+значение это строка для блока import (с алиасом если имя не совпадает с путем)
+*/
+func fileImports(src *ast.File) map[string]string {
+	imports := make(map[string]string)
+	for _, spec := range src.Imports {
+		path, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		if spec.Name != nil {
+			if spec.Name.Name != "_" && spec.Name.Name != "." {
+				imports[spec.Name.Name] = spec.Name.Name + " " + strconv.Quote(path)
+			}
+			continue
+		}
+		imports[importName(path)] = strconv.Quote(path)
+	}
+	return imports
+}
+
+/*
+имя пакета по пути импорта без алиаса: последний элемент без суффикса версии,
+gopkg.in/yaml.v3 -> yaml, github.com/jackc/pgx/v5 -> pgx, github.com/go-sql-driver/mysql -> mysql
+*/
+func importName(path string) string {
+	parts := strings.Split(path, "/")
+	name := parts[len(parts)-1]
+	if len(parts) > 1 && len(name) > 1 && name[0] == 'v' && strings.Trim(name[1:], "0123456789") == "" {
+		name = parts[len(parts)-2]
+	}
+	if dot := strings.IndexByte(name, '.'); dot > 0 {
+		name = name[:dot]
+	}
+	name = strings.TrimPrefix(name, "go-")
+	return strings.ReplaceAll(name, "-", "_")
+}
+
+/*
+имя переменной со столбцами: DBAuth -> AuthCols
+*/
+func colsName(name string) string {
+	if len(name) > 2 && strings.HasPrefix(name, "DB") && unicode.IsUpper(rune(name[2])) {
+		name = name[2:]
+	}
+	return name + "Cols"
+}
+
+/*
+имя файла для результата: auth.go -> auth_dbnames.go, auth_test.go -> auth_dbnames_test.go
+*/
+func generatedFileName(file string) string {
+	base := strings.TrimSuffix(file, ".go")
+	if strings.HasSuffix(base, "_test") {
+		return strings.TrimSuffix(base, "_test") + "_dbnames_test.go"
+	}
+	return base + "_dbnames.go"
+}
+
+func loadPackage(file string) (*genPackage, error) {
+	dir := filepath.Dir(file)
+	withTests := strings.HasSuffix(file, "_test.go")
+	fset := token.NewFileSet()
+	pkg := &genPackage{types: make(map[string]ast.Expr), consts: make(map[string]string), fset: fset}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") || (!withTests && strings.HasSuffix(name, "_test.go")) {
+			continue
+		}
+		if strings.HasSuffix(name, "_dbnames.go") || strings.HasSuffix(name, "_dbnames_test.go") {
+			continue
+		}
+		src, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		if filepath.Join(dir, name) == filepath.Clean(file) {
+			pkg.name = src.Name.Name
+		}
+		for _, decl := range src.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					pkg.types[s.Name.Name] = s.Type
+				case *ast.ValueSpec:
+					if gen.Tok != token.CONST || len(s.Names) != len(s.Values) {
+						continue
+					}
+					for i, value := range s.Values {
+						if lit, ok := value.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+							if str, err := strconv.Unquote(lit.Value); err == nil {
+								pkg.consts[s.Names[i].Name] = str
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	if len(pkg.name) == 0 {
+		return nil, fmt.Errorf("%s not found", file)
+	}
+	return pkg, nil
+}
+
+var builtinKinds = map[string]struct {
+	kind string
+	bits int
+}{
+	"string":  {"string", 0},
+	"int":     {"int", 64},
+	"int8":    {"int", 8},
+	"int16":   {"int", 16},
+	"int32":   {"int", 32},
+	"int64":   {"int", 64},
+	"uint":    {"uint", 64},
+	"uint8":   {"uint", 8},
+	"uint16":  {"uint", 16},
+	"uint32":  {"uint", 32},
+	"uint64":  {"uint", 64},
+	"byte":    {"uint", 8},
+	"rune":    {"int", 32},
+	"float32": {"float", 32},
+	"float64": {"float", 64},
+	"bool":    {"bool", 0},
+}
+
+func (pkg *genPackage) field(name string, column string, expr ast.Expr) genField {
+	res := genField{name: name, column: column}
+	if star, ok := expr.(*ast.StarExpr); ok {
+		res.pointer = true
+		expr = star.X
+	}
+	var buf bytes.Buffer
+	printer.Fprint(&buf, pkg.fset, expr)
+	res.typeExpr = buf.String()
+	ast.Inspect(expr, func(node ast.Node) bool {
+		if sel, ok := node.(*ast.SelectorExpr); ok {
+			if x, ok := sel.X.(*ast.Ident); ok {
+				if spec, ok := pkg.imports[x.Name]; ok {
+					res.imports = append(res.imports, spec)
+				}
+			}
+			return false
+		}
+		return true
+	})
+	res.kind, res.bits = pkg.resolve(expr, 0)
+	return res
+}
+
+/*
+сводит тип к виду для разбора строки, как это делает FillByDBResult по reflect.Kind
+*/
+func (pkg *genPackage) resolve(expr ast.Expr, depth int) (string, int) {
+	if depth > 10 {
+		return "custom", 0
+	}
+	switch e := expr.(type) {
+	case *ast.Ident:
+		if e.Name == "MYSQLDATETIME" && pkg.name == "dbnames" {
+			return "fromstring", 0
+		}
+		if decl, ok := pkg.types[e.Name]; ok {
+			return pkg.resolve(decl, depth+1)
+		}
+		if builtin, ok := builtinKinds[e.Name]; ok {
+			return builtin.kind, builtin.bits
+		}
+	case *ast.SelectorExpr:
+		if x, ok := e.X.(*ast.Ident); ok && x.Name == "dbnames" && e.Sel.Name == "MYSQLDATETIME" {
+			return "fromstring", 0
+		}
+	case *ast.ParenExpr:
+		return pkg.resolve(e.X, depth+1)
+	}
+	return "custom", 0
+}
+
+func (pkg *genPackage) generate(structs []genStruct) ([]byte, error) {
+	qualifier := "dbnames."
+	if pkg.name == "dbnames" {
+		qualifier = ""
+	}
+	imports := map[string]bool{}
+	if len(qualifier) > 0 {
+		imports[strconv.Quote("github.com/denisbdn/dbnames")] = true
+	}
+	var body bytes.Buffer
+	for _, st := range structs {
+		columns := make([]string, 0, len(st.fields))
+		fields := make([]string, 0, len(st.fields))
+		for _, field := range st.fields {
+			columns = append(columns, "`"+field.column+"`")
+			if len(st.table) > 0 {
+				fields = append(fields, "`"+st.table+"`.`"+field.column+"`")
+			}
+		}
+		fmt.Fprintf(&body, "// столбцы %s, то же что strings.Join(BuildFields(\"\", %s{}), \", \")\n", st.name, st.name)
+		fmt.Fprintf(&body, "const %sColumns = %s\n\n", st.name, strconv.Quote(strings.Join(columns, ", ")))
+		if len(fields) > 0 {
+			fmt.Fprintf(&body, "// столбцы %s с таблицей, то же что strings.Join(BuildFields(%sTable, %s{}), \", \")\n", st.name, st.name, st.name)
+			fmt.Fprintf(&body, "const %sFields = %s\n\n", st.name, strconv.Quote(strings.Join(fields, ", ")))
+		}
+
+		colsName := colsName(st.name)
+		fmt.Fprintf(&body, "// типизированные столбцы %s для условий и списков полей, см Col\n", st.name)
+		fmt.Fprintf(&body, "var %s = struct {\n", colsName)
+		for _, field := range st.fields {
+			fmt.Fprintf(&body, "\t%s %sCol[%s]\n", field.name, qualifier, field.typeExpr)
+			for _, spec := range field.imports {
+				imports[spec] = true
+			}
+		}
+		fmt.Fprintf(&body, "}{\n")
+		for _, field := range st.fields {
+			fmt.Fprintf(&body, "\t%s: %sNewCol[%s](%q),\n", field.name, qualifier, field.typeExpr, field.column)
+		}
+		fmt.Fprintf(&body, "}\n\n")
+
+		fmt.Fprintf(&body, "// FillFromDBResult заполняет %s из текущей строки результата без рефлексии и возвращает\n", st.name)
+		fmt.Fprintf(&body, "// число заполненных полей, поле которое не удалось разобрать пропускается, см FillByDBResult\n")
+		fmt.Fprintf(&body, "func (t *%s) FillFromDBResult(res *%sDBResult) int {\n\tcount := 0\n", st.name, qualifier)
+		for _, field := range st.fields {
+			fmt.Fprintf(&body, "\tif raw, err := res.GetRawBytes(%q); err == nil {\n", field.column)
+			// store присваивает разобранное значение v полю и считает его
+			store := fmt.Sprintf("t.%s = v\ncount++\n", field.name)
+			if field.pointer {
+				fmt.Fprintf(&body, "if raw == nil {\nt.%s = nil\n} else {\n", field.name)
+				store = fmt.Sprintf("t.%s = &v\ncount++\n", field.name)
+			} else {
+				fmt.Fprintf(&body, "if raw != nil {\n")
+			}
+			switch field.kind {
+			case "string":
+				fmt.Fprintf(&body, "v := %s(raw)\n%s", field.typeExpr, store)
+			case "int", "uint", "float", "bool":
+				imports[`"strconv"`] = true
+				parse := map[string]string{
+					"int":   fmt.Sprintf("strconv.ParseInt(string(raw), 10, %d)", field.bits),
+					"uint":  fmt.Sprintf("strconv.ParseUint(string(raw), 10, %d)", field.bits),
+					"float": fmt.Sprintf("strconv.ParseFloat(string(raw), %d)", field.bits),
+					"bool":  "strconv.ParseBool(string(raw))",
+				}[field.kind]
+				fmt.Fprintf(&body, "if parsed, err := %s; err == nil {\nv := %s(parsed)\n%s}\n", parse, field.typeExpr, store)
+			case "fromstring":
+				if field.pointer {
+					fmt.Fprintf(&body, "var v %s\nv.FromString(string(raw))\n%s", field.typeExpr, store)
+				} else {
+					fmt.Fprintf(&body, "t.%s.FromString(string(raw))\ncount++\n", field.name)
+				}
+			default:
+				if field.pointer {
+					fmt.Fprintf(&body, "var v %s\nif fs, ok := interface{}(&v).(%sFromStringInteface); ok {\nfs.FromString(string(raw))\n%s}\n", field.typeExpr, qualifier, store)
+				} else {
+					fmt.Fprintf(&body, "if fs, ok := interface{}(&t.%s).(%sFromStringInteface); ok {\nfs.FromString(string(raw))\ncount++\n}\n", field.name, qualifier)
+				}
+			}
+			fmt.Fprintf(&body, "}\n}\n")
+		}
+		fmt.Fprintf(&body, "\treturn count\n}\n\n")
+	}
+	var code bytes.Buffer
+	code.WriteString(header)
+	fmt.Fprintf(&code, "package %s\n\n", pkg.name)
+	if len(imports) > 0 {
+		paths := make([]string, 0, len(imports))
+		for path := range imports {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		fmt.Fprintf(&code, "import (\n%s\n)\n\n", strings.Join(paths, "\n"))
+	}
+	code.Write(body.Bytes())
+	return format.Source(code.Bytes())
+}
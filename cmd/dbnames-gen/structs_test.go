@@ -0,0 +1,140 @@
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const modelsSource = `package models
+
+import (
+	"time"
+
+	"github.com/denisbdn/dbnames"
+)
+
+const DBSessionTable = "session"
+
+type SessionState string
+
+type DBSession struct {
+	Id      uint64                 ` + "`db:\"id\"`" + `
+	Token   *string                ` + "`db:\"token\"`" + `
+	State   SessionState           ` + "`db:\"state\"`" + `
+	Expire  *dbnames.MYSQLDATETIME ` + "`db:\"expire\"`" + `
+	Score   float32                ` + "`db:\"score\"`" + `
+	Seen    time.Time              ` + "`db:\"seen\"`" + `
+	Active  bool                   ` + "`db:\"active\"`" + `
+	private int                    ` + "`db:\"private\"`" + `
+	NoTag   string
+}
+
+type NoColumns struct {
+	Name string
+}
+`
+
+func TestGenerateFromSource(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "models.go")
+	if err := os.WriteFile(file, []byte(modelsSource), 0644); err != nil {
+		t.Fatal(err)
+	}
+	code, err := generateFromSource(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(code)
+	t.Log(src)
+	if _, err := parser.ParseFile(token.NewFileSet(), "models_dbnames.go", code, 0); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"const DBSessionColumns = \"`id`, `token`, `state`, `expire`, `score`, `seen`, `active`\"",
+		"const DBSessionFields = \"`session`.`id`, `session`.`token`",
+		"func (t *DBSession) FillFromDBResult(res *dbnames.DBResult) int {",
+		"if parsed, err := strconv.ParseUint(string(raw), 10, 64); err == nil {",
+		"v := string(raw)\n\t\t\tt.Token = &v",
+		"if parsed, err := strconv.ParseBool(string(raw)); err == nil {",
+		"v := SessionState(raw)",
+		"var v dbnames.MYSQLDATETIME",
+		"strconv.ParseFloat(string(raw), 32)",
+		"interface{}(&t.Seen).(dbnames.FromStringInteface)",
+		"var SessionCols = struct {",
+		"Token  dbnames.Col[string]",
+		`Expire: dbnames.NewCol[dbnames.MYSQLDATETIME]("expire"),`,
+	}
+	for _, e := range expected {
+		if !strings.Contains(src, e) {
+			t.Errorf("no %s in generated code", e)
+		}
+	}
+	if strings.Contains(src, "NoColumns") || strings.Contains(src, "private") {
+		t.Errorf("generated code for fields without db tags")
+	}
+
+	if name := generatedFileName("dir/auth_test.go"); name != "dir/auth_dbnames_test.go" {
+		t.Errorf("bad file name %s", name)
+	}
+}
+
+const importsSource = `package models
+
+import (
+	dbsql "database/sql"
+	"time"
+
+	"github.com/denisbdn/dbnames"
+)
+
+type DBEvent struct {
+	Id     uint64               ` + "`db:\"id\"`" + `
+	At     time.Time            ` + "`db:\"at\"`" + `
+	Seen   *time.Time           ` + "`db:\"seen\"`" + `
+	Note   dbsql.NullString     ` + "`db:\"note\"`" + `
+	Closed *dbsql.NullTime      ` + "`db:\"closed\"`" + `
+	Create dbnames.MYSQLDATETIME ` + "`db:\"create\"`" + `
+}
+`
+
+func TestGenerateImports(t *testing.T) {
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("no go tool")
+	}
+	// пакет внутри модуля, чтобы импорт dbnames разрешался, testdata не попадает в ./...
+	if err := os.MkdirAll("testdata", 0755); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("testdata")
+	dir, err := os.MkdirTemp("testdata", "gen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "models.go")
+	if err := os.WriteFile(file, []byte(importsSource), 0644); err != nil {
+		t.Fatal(err)
+	}
+	code, err := generateFromSource(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(code)
+	for _, e := range []string{`dbsql "database/sql"`, `"time"`, "Note   dbnames.Col[dbsql.NullString]"} {
+		if !strings.Contains(src, e) {
+			t.Errorf("no %s in generated code\n%s", e, src)
+		}
+	}
+	if err := os.WriteFile(generatedFileName(file), code, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := exec.Command(goTool, "vet", "./"+filepath.ToSlash(dir)).CombinedOutput(); err != nil {
+		t.Errorf("generated code does not compile: %v\n%s\n%s", err, out, src)
+	}
+}
@@ -0,0 +1,150 @@
+package dbnames
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// эта часть файла добавляет к запросам комментарий с тегами запроса в стиле sqlcommenter
+
+// CommentExtractor достает значение тега из контекста, false если тега нет
+type CommentExtractor func(ctx context.Context) (string, bool)
+
+var (
+	commentMutex      sync.RWMutex
+	commentExtractors = make(map[string]CommentExtractor)
+)
+
+/*
+функция регистрирует глобальный извлекатель тега key (например trace_id из span трассировки),
+повторная регистрация заменяет предыдущую, nil удаляет
+*/
+func RegisterCommentExtractor(key string, extractor CommentExtractor) {
+	commentMutex.Lock()
+	defer commentMutex.Unlock()
+	if extractor == nil {
+		delete(commentExtractors, key)
+		return
+	}
+	commentExtractors[key] = extractor
+}
+
+type commentTagsKey struct{}
+
+/*
+функция возвращает контекст с тегом комментария key=value, теги из контекста
+важнее извлекателей и статических тегов WithComments
+*/
+func WithCommentTag(ctx context.Context, key, value string) context.Context {
+	parent, _ := ctx.Value(commentTagsKey{}).(map[string]string)
+	tags := make(map[string]string, len(parent)+1)
+	for k, v := range parent {
+		tags[k] = v
+	}
+	tags[key] = value
+	return context.WithValue(ctx, commentTagsKey{}, tags)
+}
+
+// функция собирает комментарий "/* key='value',... */" из static, зарегистрированных
+// извлекателей и тегов контекста, ключи отсортированы, ключи и значения кодируются как в URL
+// (поэтому конец комментария и кавычки внутри не появятся), пустая строка если тегов нет
+func BuildComment(ctx context.Context, static map[string]string) string {
+	tags := make(map[string]string, len(static))
+	for k, v := range static {
+		tags[k] = v
+	}
+	commentMutex.RLock()
+	for key, extractor := range commentExtractors {
+		if value, ok := extractor(ctx); ok {
+			tags[key] = value
+		}
+	}
+	commentMutex.RUnlock()
+	if fromCtx, ok := ctx.Value(commentTagsKey{}).(map[string]string); ok {
+		for k, v := range fromCtx {
+			tags[k] = v
+		}
+	}
+	if len(tags) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, escapeCommentPart(key)+"='"+escapeCommentPart(tags[key])+"'")
+	}
+	return "/* " + strings.Join(pairs, ",") + " */"
+}
+
+/*
+внутрення функция пакета, процентное кодирование как в URL, но / : @ остаются читаемыми,
+звездочка кодируется всегда, поэтому ни начала ни конца комментария в значении не будет
+*/
+func escapeCommentPart(part string) string {
+	var out strings.Builder
+	for i := 0; i < len(part); i++ {
+		c := part[i]
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte("-_.~/:@", c) >= 0 {
+			out.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&out, "%%%02X", c)
+	}
+	return out.String()
+}
+
+// функция дописывает комментарий BuildComment в конец запроса (перед завершающей ;),
+// запрос который уже заканчивается комментарием (например от другой обертки) не меняется,
+// подсказки оптимизатора "/*+ ... */" и комментарии внутри запроса этому не мешают
+func AppendComment(ctx context.Context, query string, static map[string]string) string {
+	trimmed := strings.TrimRight(query, " \t\n\r")
+	semicolon := strings.HasSuffix(trimmed, ";")
+	if semicolon {
+		trimmed = strings.TrimRight(trimmed[:len(trimmed)-1], " \t\n\r")
+	}
+	if strings.HasSuffix(trimmed, "*/") {
+		return query
+	}
+	comment := BuildComment(ctx, static)
+	if comment == "" {
+		return query
+	}
+	if semicolon {
+		return trimmed + " " + comment + ";"
+	}
+	return trimmed + " " + comment
+}
+
+type commentQuerier struct {
+	q      Querier
+	static map[string]string
+}
+
+/*
+функция оборачивает q так, что к каждому запросу дописывается комментарий AppendComment
+со статическими тегами static (например app), хуки WithHooks поверх этой обертки
+видят запрос без комментария
+*/
+func WithComments(q Querier, static map[string]string) Querier {
+	return &commentQuerier{q: q, static: static}
+}
+
+func (c *commentQuerier) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return c.q.ExecContext(ctx, AppendComment(ctx, query, c.static), args...)
+}
+
+func (c *commentQuerier) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return c.q.QueryContext(ctx, AppendComment(ctx, query, c.static), args...)
+}
+
+func (c *commentQuerier) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return c.q.QueryRowContext(ctx, AppendComment(ctx, query, c.static), args...)
+}
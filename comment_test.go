@@ -0,0 +1,58 @@
+package dbnames
+
+import (
+	"context"
+	"testing"
+
+	"github.com/denisbdn/dbnames/dbnamestest"
+)
+
+type traceKey struct{}
+
+func TestAppendComment(t *testing.T) {
+	RegisterCommentExtractor("trace_id", func(ctx context.Context) (string, bool) {
+		trace, ok := ctx.Value(traceKey{}).(string)
+		return trace, ok
+	})
+	defer RegisterCommentExtractor("trace_id", nil)
+	static := map[string]string{"app": "auth"}
+	ctx := context.Background()
+	if query := AppendComment(ctx, "SELECT 1", nil); query != "SELECT 1" {
+		t.Errorf("comment without tags %s", query)
+	}
+	ctx = context.WithValue(ctx, traceKey{}, "4bf92f35")
+	ctx = WithCommentTag(ctx, "route", "/auth")
+	if query := AppendComment(ctx, "SELECT 1;\n", static); query != "SELECT 1 /* app='auth',route='/auth',trace_id='4bf92f35' */;" {
+		t.Errorf("bad comment %s", query)
+	}
+	// значения не могут закрыть комментарий или строку
+	evil := WithCommentTag(ctx, "route", "*/ DROP TABLE `auth`; /* it's")
+	if comment := BuildComment(evil, nil); comment != "/* route='%2A/%20DROP%20TABLE%20%60auth%60%3B%20/%2A%20it%27s',trace_id='4bf92f35' */" {
+		t.Errorf("bad escape %s", comment)
+	}
+	// подсказка оптимизатора не мешает комментарию, а готовый комментарий в конце не дублируется
+	hinted := "SELECT /*+ MAX_EXECUTION_TIME(100) */ 1"
+	if query := AppendComment(ctx, hinted, static); query != hinted+" /* app='auth',route='/auth',trace_id='4bf92f35' */" {
+		t.Errorf("bad hinted comment %s", query)
+	}
+	if query := AppendComment(ctx, "SELECT 1 /* app='auth' */;", static); query != "SELECT 1 /* app='auth' */;" {
+		t.Errorf("commented query changed %s", query)
+	}
+	// тег контекста важнее статического
+	if comment := BuildComment(WithCommentTag(ctx, "app", "admin"), static); comment != "/* app='admin',route='/auth',trace_id='4bf92f35' */" {
+		t.Errorf("bad override %s", comment)
+	}
+
+	db, mock, err := dbnamestest.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	mock.ExpectExec("DELETE FROM `auth` WHERE `user_id` = ? /* app='auth',route='/auth',trace_id='4bf92f35' */").WithArgs(1)
+	if _, err := WithComments(db, static).ExecContext(ctx, "DELETE FROM `auth` WHERE `user_id` = ?", 1); err != nil {
+		t.Error(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
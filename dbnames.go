@@ -2,11 +2,14 @@ package dbnames
 
 import (
 	"database/sql"
+	"database/sql/driver"
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -20,6 +23,9 @@ const (
 	BDERRORUSERNOTFOUND
 	BDERRORUSEREXIST
 	BDERRORIP
+	BDERRORDEADLOCK
+	BDERRORLOCKTIMEOUT
+	BDERRORFOREIGNKEY
 )
 
 // эта часть файла помогает парсить результат из базы
@@ -34,6 +40,8 @@ type DBResult struct {
 	scanArgs []interface{}
 	values   []sql.RawBytes
 	names    map[string]int
+	// отмена таймаута Query, вызывается в Close
+	cancel func()
 }
 
 func New(results *sql.Rows) (*DBResult, error) {
@@ -61,6 +69,24 @@ func (res *DBResult) Next() bool {
 	return res.results.Next()
 }
 
+/*
+ошибка чтения результата после цикла Next (классифицированная, см Classify)
+*/
+func (res *DBResult) Err() error {
+	return Classify(res.results.Err())
+}
+
+/*
+закрывает результат и освобождает таймаут Query, повторный вызов безопасен
+*/
+func (res *DBResult) Close() error {
+	err := res.results.Close()
+	if res.cancel != nil {
+		res.cancel()
+	}
+	return err
+}
+
 /*
  * внутрення функция пакета, выдает номер столбца
  */
@@ -198,6 +224,17 @@ func (t *MYSQLDATETIME) UnmarshalJSON(data []byte) error {
 	}
 }
 
+/*
+значение для параметра запроса (database/sql/driver.Valuer): нулевое время это текущее время,
+как NOW() в ToString. Для NULL в колонке нужно поле *MYSQLDATETIME, nil отправляется как NULL
+*/
+func (t MYSQLDATETIME) Value() (driver.Value, error) {
+	if t.IsNULL() {
+		return time.Now(), nil
+	}
+	return time.Time(t), nil
+}
+
 func (t *MYSQLDATETIME) IsNULL() bool {
 	zero := MYSQLDATETIME{}
 	if zero == *t {
@@ -284,6 +321,7 @@ name это название тега db для побличного поля с
 "crc", "count"
 */
 func BuildFields(table string, equal interface{}, fields ...string) []string {
+	table = resolveTable(table, equal)
 	fullFields := make([]string, 0)
 	ct := reflect.TypeOf(equal)
 	for i := 0; i < ct.NumField(); i++ {
@@ -365,6 +403,8 @@ const (
 	MOREQE
 	IN
 	NOTIN
+	LIKE
+	NOTLIKE
 )
 
 func (oper Operation) ToString(condition string) string {
@@ -390,6 +430,10 @@ func (oper Operation) ToString(condition string) string {
 		res = fmt.Sprintf(" IN (%s)", condition)
 	case NOTIN:
 		res = fmt.Sprintf(" NOT IN (%s)", condition)
+	case LIKE:
+		res = fmt.Sprintf(" LIKE %s", condition)
+	case NOTLIKE:
+		res = fmt.Sprintf(" NOT LIKE %s", condition)
 	}
 	return res
 }
@@ -418,8 +462,26 @@ operation, но само сравнение происходит с объект
 если поставить между ними ADN - получим условие с кем разговаривал юзер 1122
 (неважно в какой роли звонящий или вызываемый).
 Данная функция не проставляет AND OR или скобки она лишь генерирует базовые фильтры для sql запроса
+Если data нельзя привести к типу поля то условие все равно строится, чтобы фильтр
+не пропал молча: data выводится через %v, но для числовых полей только если это число,
+иначе как строка в кавычках с экранированием (user_id='1 OR 1=1'), сырой текст из data
+в запрос не попадает никогда.
+
+Deprecated: используйте BuildTypedCondition, она возвращает ошибку для неподходящего data.
 */
 func BuildCondition(table string, fields interface{}, operation Operation, data interface{}) []string {
+	fillCond, err := BuildTypedCondition(table, fields, operation, data)
+	if err != nil {
+		return untypedCondition(resolveTable(table, fields), fields, operation, data)
+	}
+	return fillCond
+}
+
+/*
+внутрення функция пакета, прежний вариант BuildCondition без проверки типа data:
+значение выводится через untypedNumber (строки в кавычках), а структуры через ToStringInteface
+*/
+func untypedCondition(table string, fields interface{}, operation Operation, data interface{}) []string {
 	fillCond := make([]string, 0)
 	fieldsType := reflect.TypeOf(fields)
 	fieldsValue := reflect.ValueOf(fields)
@@ -427,49 +489,31 @@ func BuildCondition(table string, fields interface{}, operation Operation, data
 	for i := 0; i < fieldsType.NumField(); i++ {
 		fieldType := fieldsType.Field(i)
 		if dbFieldName, find := fieldType.Tag.Lookup("db"); find {
-			var fullField string
-			if len(table) > 0 {
-				fullField = fmt.Sprintf("`%s`.`%s`", table, dbFieldName)
-			} else {
-				fullField = fmt.Sprintf("`%s`", dbFieldName)
-			}
+			fullField := fullFieldName(table, dbFieldName)
 			fieldValue := fieldsValue.Field(i)
-			kindValue := fieldValue.Kind()
-			switch kindValue {
+			switch fieldValue.Kind() {
 			case reflect.String:
 				if len(fieldValue.String()) > 0 {
-					s := strings.ReplaceAll(fmt.Sprintf("%v", data), "'", "\\'")
+					s := escapeString(fmt.Sprintf("%v", data))
 					fillCond = append(fillCond, " "+fullField+operation.ToString(fmt.Sprintf("'%s'", s)))
 				}
 			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 				if fieldValue.Int() != 0 {
-					fillCond = append(fillCond, " "+fullField+operation.ToString(fmt.Sprintf("%v", data)))
+					fillCond = append(fillCond, " "+fullField+operation.ToString(untypedNumber(data)))
 				}
 			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
 				if fieldValue.Uint() != 0 {
-					fillCond = append(fillCond, " "+fullField+operation.ToString(fmt.Sprintf("%v", data)))
+					fillCond = append(fillCond, " "+fullField+operation.ToString(untypedNumber(data)))
 				}
 			case reflect.Float32, reflect.Float64:
 				if fieldValue.Float() != 0 {
-					fillCond = append(fillCond, " "+fullField+operation.ToString(fmt.Sprintf("%v", data)))
+					fillCond = append(fillCond, " "+fullField+operation.ToString(untypedNumber(data)))
 				}
 			default:
-				/*
-					fields.field := SomeStruct{}
-					func (t *SomeStruct) ToString() string {
-						return "..."
-					}
-				*/
-				if fieldsValue.IsValid() && fieldType.Type == reflect.TypeOf(data) && !fieldValue.IsZero() && reflect.PointerTo(reflect.TypeOf(data)).Implements(toStringType) {
+				if fieldType.Type == reflect.TypeOf(data) && !fieldValue.IsZero() && reflect.PointerTo(reflect.TypeOf(data)).Implements(toStringType) {
 					pdata := reflect.New(reflect.TypeOf(data))
 					pdata.Elem().Set(reflect.ValueOf(data))
-					tc := pdata.MethodByName("ToString")
-					if tc.IsValid() {
-						arr := tc.Call([]reflect.Value{})
-						if len(arr) == 1 {
-							fillCond = append(fillCond, " "+fullField+operation.ToString(arr[0].String()))
-						}
-					}
+					fillCond = append(fillCond, " "+fullField+operation.ToString(pdata.Interface().(ToStringInteface).ToString()))
 				}
 			}
 		}
@@ -477,123 +521,402 @@ func BuildCondition(table string, fields interface{}, operation Operation, data
 	return fillCond
 }
 
+/*
+см функцию BuildCondition. Отличие в том что data проверяется для каждого отмеченного поля:
+значение должно присваиваться или приводиться к типу поля (time.Time к MYSQLDATETIME, int к
+uint32 без переполнения и т.п.), иначе возвращается ошибка. Строка для запроса формируется
+по правилам типа поля, а не типа data. Для IN и NOTIN data может быть слайсом значений,
+для ISNULL и ISNOTNULL data не используется.
+*/
+func BuildTypedCondition(table string, fields interface{}, operation Operation, data interface{}) ([]string, error) {
+	table = resolveTable(table, fields)
+	fillCond := make([]string, 0)
+	fieldsType := reflect.TypeOf(fields)
+	fieldsValue := reflect.ValueOf(fields)
+	for i := 0; i < fieldsType.NumField(); i++ {
+		fieldType := fieldsType.Field(i)
+		if dbFieldName, find := fieldType.Tag.Lookup("db"); find {
+			fieldValue := fieldsValue.Field(i)
+			if fieldValue.IsZero() {
+				continue
+			}
+			targetType := fieldType.Type
+			if targetType.Kind() == reflect.Pointer {
+				targetType = targetType.Elem()
+			}
+			literal, err := dataLiteral(targetType, operation, data)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", dbFieldName, err)
+			}
+			fillCond = append(fillCond, " "+fullFieldName(table, dbFieldName)+operation.ToString(literal))
+		}
+	}
+	return fillCond, nil
+}
+
+/*
+внутрення функция пакета, приводит data к типу target и выдает sql литерал,
+для IN и NOTIN слайс превращается в список через запятую
+*/
+func dataLiteral(target reflect.Type, operation Operation, data interface{}) (string, error) {
+	if operation == ISNULL || operation == ISNOTNULL {
+		return "", nil
+	}
+	value := reflect.ValueOf(data)
+	if (operation == IN || operation == NOTIN) && value.IsValid() &&
+		(value.Kind() == reflect.Slice || value.Kind() == reflect.Array) && value.Type() != target {
+		if value.Len() == 0 {
+			return "", fmt.Errorf("empty list")
+		}
+		literals := make([]string, 0, value.Len())
+		for i := 0; i < value.Len(); i++ {
+			literal, err := convertLiteral(target, value.Index(i))
+			if err != nil {
+				return "", err
+			}
+			literals = append(literals, literal)
+		}
+		return strings.Join(literals, ", "), nil
+	}
+	return convertLiteral(target, value)
+}
+
+func convertLiteral(target reflect.Type, value reflect.Value) (string, error) {
+	converted, err := convertValue(target, value)
+	if err != nil {
+		return "", err
+	}
+	literal, ok := valueLiteral(converted)
+	if !ok {
+		return "", fmt.Errorf("type %s has no sql representation", target)
+	}
+	return literal, nil
+}
+
+/*
+внутрення функция пакета, приводит значение к типу target: допускается присваивание,
+приведение числа к числу без переполнения, строки к строке и структуры к структуре
+с той же раскладкой (time.Time и MYSQLDATETIME). Строку в число и число в строку не приводим.
+*/
+func convertValue(target reflect.Type, value reflect.Value) (reflect.Value, error) {
+	for value.IsValid() && value.Kind() == reflect.Interface {
+		value = value.Elem()
+	}
+	if !value.IsValid() {
+		return reflect.Value{}, fmt.Errorf("nil value for %s", target)
+	}
+	if value.Kind() == reflect.Pointer && value.Type() != target {
+		if value.IsNil() {
+			return reflect.Value{}, fmt.Errorf("nil value for %s", target)
+		}
+		value = value.Elem()
+	}
+	if value.Type().AssignableTo(target) {
+		res := reflect.New(target).Elem()
+		res.Set(value)
+		return res, nil
+	}
+	bad := fmt.Errorf("can't use %s as %s", value.Type(), target)
+	if !value.Type().ConvertibleTo(target) {
+		return reflect.Value{}, bad
+	}
+	switch kindClass(target.Kind()) {
+	case reflect.Int:
+		switch kindClass(value.Kind()) {
+		case reflect.Int:
+			if reflect.Zero(target).OverflowInt(value.Int()) {
+				return reflect.Value{}, fmt.Errorf("%d overflows %s", value.Int(), target)
+			}
+		case reflect.Uint:
+			if value.Uint() > 1<<63-1 || reflect.Zero(target).OverflowInt(int64(value.Uint())) {
+				return reflect.Value{}, fmt.Errorf("%d overflows %s", value.Uint(), target)
+			}
+		default:
+			return reflect.Value{}, bad
+		}
+	case reflect.Uint:
+		switch kindClass(value.Kind()) {
+		case reflect.Int:
+			if value.Int() < 0 || reflect.Zero(target).OverflowUint(uint64(value.Int())) {
+				return reflect.Value{}, fmt.Errorf("%d overflows %s", value.Int(), target)
+			}
+		case reflect.Uint:
+			if reflect.Zero(target).OverflowUint(value.Uint()) {
+				return reflect.Value{}, fmt.Errorf("%d overflows %s", value.Uint(), target)
+			}
+		default:
+			return reflect.Value{}, bad
+		}
+	case reflect.Float64:
+		switch kindClass(value.Kind()) {
+		case reflect.Int, reflect.Uint, reflect.Float64:
+		default:
+			return reflect.Value{}, bad
+		}
+	default:
+		if value.Kind() != target.Kind() {
+			return reflect.Value{}, bad
+		}
+	}
+	return value.Convert(target), nil
+}
+
+/*
+внутрення функция пакета, сводит все целые знаковые к reflect.Int,
+беззнаковые к reflect.Uint и дробные к reflect.Float64
+*/
+func kindClass(kind reflect.Kind) reflect.Kind {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return reflect.Int
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return reflect.Uint
+	case reflect.Float32, reflect.Float64:
+		return reflect.Float64
+	}
+	return kind
+}
+
 /*
 см функцию BuildCondition. Эта может создавать разные условия для разных полей, но с
 одним оператором сравнения, еще одно ограничение сравнение с нулевым полем пропускаются
 обычно operation это операция сравнения.
+Поля-указатели обрабатываются отдельно: nil пропускается, а не nil значение попадает
+в условие даже если оно нулевое (например *AuthType указывающий на 0).
 */
 func BuildConditions(table string, fields interface{}, operation Operation) []string {
+	return buildConditions(table, fields, operation, nil)
+}
+
+/*
+аналог BuildConditions, но поля с тегами db перечисленные в zeroFields попадают в условие
+даже если их значение нулевое: так можно отфильтровать `auth_type`=0 или пустую строку в `desc`
+
+	BuildConditionsWithZero("auth", DBAuth{UserId: 10}, EQUAL, "auth_type")
+	// [ `auth`.`user_id`=10  `auth`.`auth_type`=0]
+*/
+func BuildConditionsWithZero(table string, fields interface{}, operation Operation, zeroFields ...string) []string {
+	return buildConditions(table, fields, operation, zeroFields)
+}
+
+func buildConditions(table string, fields interface{}, operation Operation, zeroFields []string) []string {
+	table = resolveTable(table, fields)
 	fillCond := make([]string, 0)
 	fieldsType := reflect.TypeOf(fields)
 	fieldsValue := reflect.ValueOf(fields)
-	toStringType := reflect.TypeOf((*ToStringInteface)(nil)).Elem()
 	for i := 0; i < fieldsType.NumField(); i++ {
 		fieldType := fieldsType.Field(i)
 		if dbFieldName, find := fieldType.Tag.Lookup("db"); find {
-			var fullField string
-			if len(table) > 0 {
-				fullField = fmt.Sprintf("`%s`.`%s`", table, dbFieldName)
-			} else {
-				fullField = fmt.Sprintf("`%s`", dbFieldName)
-			}
 			fieldValue := fieldsValue.Field(i)
-			kindValue := fieldValue.Kind()
-			switch kindValue {
-			case reflect.String:
-				if len(fieldValue.String()) > 0 {
-					s := strings.ReplaceAll(fieldValue.String(), "'", "\\'")
-					fillCond = append(fillCond, " "+fullField+operation.ToString(fmt.Sprintf("'%s'", s)))
-				}
-			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-				if fieldValue.Int() != 0 {
-					fillCond = append(fillCond, " "+fullField+operation.ToString(fmt.Sprintf("%d", fieldValue.Int())))
-				}
-			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-				if fieldValue.Uint() != 0 {
-					fillCond = append(fillCond, " "+fullField+operation.ToString(fmt.Sprintf("%d", fieldValue.Uint())))
-				}
-			case reflect.Float32, reflect.Float64:
-				if fieldValue.Float() != 0 {
-					fillCond = append(fillCond, " "+fullField+operation.ToString(fmt.Sprintf("%f", fieldValue.Float())))
+			withZero := false
+			for _, f := range zeroFields {
+				if f == dbFieldName {
+					withZero = true
+					break
 				}
-			default:
-				/*
-					data := SomeStruct{}
-					func (t *SomeStruct) ToString() string {
-						return "..."
-					}
-				*/
-				if fieldsValue.IsValid() && !fieldValue.IsZero() && reflect.PointerTo(fieldType.Type).Implements(toStringType) {
-					pdata := reflect.New(fieldType.Type)
-					pdata.Elem().Set(fieldValue)
-					tc := pdata.MethodByName("ToString")
-					if tc.IsValid() {
-						arr := tc.Call([]reflect.Value{})
-						if len(arr) == 1 {
-							fillCond = append(fillCond, " "+fullField+operation.ToString(arr[0].String()))
-						}
-					}
+			}
+			if fieldValue.Kind() == reflect.Pointer {
+				if fieldValue.IsNil() {
+					continue
 				}
+				// указатель явно задан - значит и нулевое значение это условие
+				fieldValue = fieldValue.Elem()
+				withZero = true
+			}
+			if !withZero && fieldValue.IsZero() {
+				continue
+			}
+			if literal, ok := valueLiteral(fieldValue); ok {
+				fillCond = append(fillCond, " "+fullFieldName(table, dbFieldName)+operation.ToString(literal))
 			}
 		}
 	}
 	return fillCond
 }
 
+/*
+внутрення функция пакета, формирует имя столбца для запроса: `table`.`name`
+или просто `name` если таблица не задана
+*/
+func fullFieldName(table string, name string) string {
+	if len(table) > 0 {
+		return fmt.Sprintf("`%s`.`%s`", table, name)
+	}
+	return fmt.Sprintf("`%s`", name)
+}
+
+var numberLiteral = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?([eE][-+]?[0-9]+)?$`)
+
+/*
+внутрення функция пакета, data для числового столбца: число как есть, все остальное
+строкой в кавычках, чтобы текст из data не стал частью запроса
+*/
+func untypedNumber(data interface{}) string {
+	str := fmt.Sprintf("%v", data)
+	if numberLiteral.MatchString(str) {
+		return str
+	}
+	return fmt.Sprintf("'%s'", escapeString(str))
+}
+
+/*
+внутрення функция пакета, экранирует строку для подстановки в запрос в одинарных кавычках
+*/
+func escapeString(str string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(str, "\\", "\\\\"), "'", "\\'")
+}
+
+/*
+внутрення функция пакета, представляет значение в виде sql литерала: строки в кавычках,
+числа как есть, остальные типы через ToStringInteface (например MYSQLDATETIME)
+*/
+func valueLiteral(value reflect.Value) (string, bool) {
+	switch value.Kind() {
+	case reflect.String:
+		return fmt.Sprintf("'%s'", escapeString(value.String())), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return fmt.Sprintf("%d", value.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return fmt.Sprintf("%d", value.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return fmt.Sprintf("%f", value.Float()), true
+	}
+	/*
+		data := SomeStruct{}
+		func (t *SomeStruct) ToString() string {
+			return "..."
+		}
+	*/
+	toStringType := reflect.TypeOf((*ToStringInteface)(nil)).Elem()
+	if value.IsValid() && reflect.PointerTo(value.Type()).Implements(toStringType) {
+		pdata := reflect.New(value.Type())
+		pdata.Elem().Set(value)
+		if ts, ok := pdata.Interface().(ToStringInteface); ok {
+			return ts.ToString(), true
+		}
+	}
+	return "", false
+}
+
 // эта часть заполняет поля структуры из запроса, продвинутый парсинг результата
 
+/*
+структура может заполнять себя из результата без рефлексии, такой метод
+генерирует dbnames-gen -src, FillByDBResult использует его если он есть.
+Метод ведет себя как FillByDBResult: поле которое не удалось разобрать пропускается,
+возвращается число заполненных полей
+*/
+type DBResultFiller interface {
+	FillFromDBResult(res *DBResult) int
+}
+
+// столбцы структур по типу, чтобы не проходить рефлексией по полям каждый раз
+var typeColumns sync.Map
+
+/*
+внутрення функция пакета, выдает теги db структуры t
+*/
+func columnsOf(t reflect.Type) []string {
+	if columns, ok := typeColumns.Load(t); ok {
+		return columns.([]string)
+	}
+	columns := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		if dbFieldName, find := t.Field(i).Tag.Lookup("db"); find {
+			columns = append(columns, dbFieldName)
+		}
+	}
+	typeColumns.Store(t, columns)
+	return columns
+}
+
 /*
 функция заполняет структуру по указателю data на основании данных из
 БД в объекте result, который содержит *sql.Rows мап с названием столбцов
-возвращает число заполненных полей
+возвращает число заполненных полей. Поля-указатели получают nil если в базе NULL.
+Если структура имеет метод FillFromDBResult (см DBResultFiller) то используется он.
 */
 func FillByDBResult(result *DBResult, data interface{}) int {
+	if filler, ok := data.(DBResultFiller); ok {
+		return filler.FillFromDBResult(result)
+	}
 	count := 0
 	fieldsValue := reflect.ValueOf(data).Elem()
 	fieldsType := fieldsValue.Type()
-	fromStringType := reflect.TypeOf((*FromStringInteface)(nil)).Elem()
 	for i := 0; i < fieldsType.NumField(); i++ {
 		fieldType := fieldsType.Field(i)
 		if dbFieldName, find := fieldType.Tag.Lookup("db"); find {
-			valStr := result.ParseStringPtr(dbFieldName)
-			if valStr == nil {
-				continue
-			}
 			fieldValue := fieldsValue.Field(i)
-			kindValue := fieldValue.Kind()
 			if !fieldValue.CanSet() {
 				continue
 			}
-
-			switch kindValue {
-			case reflect.String:
-				fieldValue.SetString(*valStr)
-				count += 1
-			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-				if tmp, err := strconv.ParseInt(*valStr, 10, 64); err == nil {
-					fieldValue.SetInt(tmp)
-					count += 1
+			valStr := result.ParseStringPtr(dbFieldName)
+			if fieldValue.Kind() == reflect.Pointer {
+				// поле-указатель: NULL в базе это nil
+				index := result.getInt(dbFieldName)
+				if index < 0 || index >= len(result.values) {
+					continue
 				}
-			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-				if tmp, err := strconv.ParseUint(*valStr, 10, 64); err == nil {
-					fieldValue.SetUint(tmp)
-					count += 1
+				if valStr == nil {
+					fieldValue.Set(reflect.Zero(fieldType.Type))
+					continue
 				}
-			case reflect.Float32, reflect.Float64:
-				if tmp, err := strconv.ParseFloat(*valStr, 64); err == nil {
-					fieldValue.SetFloat(tmp)
+				elem := reflect.New(fieldType.Type.Elem())
+				if fillValue(elem.Elem(), *valStr) {
+					fieldValue.Set(elem)
 					count += 1
 				}
-			default:
-				if fieldValue.IsValid() && reflect.PointerTo(fieldType.Type).Implements(fromStringType) {
-					ts, ok := fieldValue.Addr().Interface().(FromStringInteface)
-					if ok {
-						ts.FromString(*valStr)
-						count += 1
-					}
-				}
+				continue
+			}
+			if valStr == nil {
+				continue
+			}
+			if fillValue(fieldValue, *valStr) {
+				count += 1
 			}
 		}
 	}
 	return count
 }
+
+/*
+внутрення функция пакета, заполняет значение из строки результата
+*/
+func fillValue(fieldValue reflect.Value, valStr string) bool {
+	switch fieldValue.Kind() {
+	case reflect.String:
+		fieldValue.SetString(valStr)
+		return true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if tmp, err := strconv.ParseInt(valStr, 10, 64); err == nil && !fieldValue.OverflowInt(tmp) {
+			fieldValue.SetInt(tmp)
+			return true
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if tmp, err := strconv.ParseUint(valStr, 10, 64); err == nil && !fieldValue.OverflowUint(tmp) {
+			fieldValue.SetUint(tmp)
+			return true
+		}
+	case reflect.Float32, reflect.Float64:
+		if tmp, err := strconv.ParseFloat(valStr, fieldValue.Type().Bits()); err == nil {
+			fieldValue.SetFloat(tmp)
+			return true
+		}
+	case reflect.Bool:
+		// MySQL отдает tinyint(1) как 0 и 1
+		if tmp, err := strconv.ParseBool(valStr); err == nil {
+			fieldValue.SetBool(tmp)
+			return true
+		}
+	default:
+		fromStringType := reflect.TypeOf((*FromStringInteface)(nil)).Elem()
+		if fieldValue.IsValid() && reflect.PointerTo(fieldValue.Type()).Implements(fromStringType) {
+			ts, ok := fieldValue.Addr().Interface().(FromStringInteface)
+			if ok {
+				ts.FromString(valStr)
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+// Code generated by dbnames-gen. DO NOT EDIT.
+
+package dbnames
+
+import (
+	"strconv"
+)
+
+// столбцы DBData, то же что strings.Join(BuildFields("", DBData{}), ", ")
+const DBDataColumns = "`crc`, `create`, `desc`"
+
+// типизированные столбцы DBData для условий и списков полей, см Col
+var DataCols = struct {
+	Crc    Col[uint32]
+	Create Col[MYSQLDATETIME]
+	Desc   Col[string]
+}{
+	Crc:    NewCol[uint32]("crc"),
+	Create: NewCol[MYSQLDATETIME]("create"),
+	Desc:   NewCol[string]("desc"),
+}
+
+// FillFromDBResult заполняет DBData из текущей строки результата без рефлексии и возвращает
+// число заполненных полей, поле которое не удалось разобрать пропускается, см FillByDBResult
+func (t *DBData) FillFromDBResult(res *DBResult) int {
+	count := 0
+	if raw, err := res.GetRawBytes("crc"); err == nil {
+		if raw != nil {
+			if parsed, err := strconv.ParseUint(string(raw), 10, 32); err == nil {
+				v := uint32(parsed)
+				t.Crc = v
+				count++
+			}
+		}
+	}
+	if raw, err := res.GetRawBytes("create"); err == nil {
+		if raw != nil {
+			t.Create.FromString(string(raw))
+			count++
+		}
+	}
+	if raw, err := res.GetRawBytes("desc"); err == nil {
+		if raw != nil {
+			v := string(raw)
+			t.Desc = v
+			count++
+		}
+	}
+	return count
+}
+
+// столбцы DBAuth, то же что strings.Join(BuildFields("", DBAuth{}), ", ")
+const DBAuthColumns = "`user_id`, `auth_type`, `create`, `data`"
+
+// столбцы DBAuth с таблицей, то же что strings.Join(BuildFields(DBAuthTable, DBAuth{}), ", ")
+const DBAuthFields = "`auth`.`user_id`, `auth`.`auth_type`, `auth`.`create`, `auth`.`data`"
+
+// типизированные столбцы DBAuth для условий и списков полей, см Col
+var AuthCols = struct {
+	UserId Col[UserIdType]
+	Type   Col[AuthType]
+	Create Col[MYSQLDATETIME]
+	Data   Col[string]
+}{
+	UserId: NewCol[UserIdType]("user_id"),
+	Type:   NewCol[AuthType]("auth_type"),
+	Create: NewCol[MYSQLDATETIME]("create"),
+	Data:   NewCol[string]("data"),
+}
+
+// FillFromDBResult заполняет DBAuth из текущей строки результата без рефлексии и возвращает
+// число заполненных полей, поле которое не удалось разобрать пропускается, см FillByDBResult
+func (t *DBAuth) FillFromDBResult(res *DBResult) int {
+	count := 0
+	if raw, err := res.GetRawBytes("user_id"); err == nil {
+		if raw != nil {
+			if parsed, err := strconv.ParseUint(string(raw), 10, 32); err == nil {
+				v := UserIdType(parsed)
+				t.UserId = v
+				count++
+			}
+		}
+	}
+	if raw, err := res.GetRawBytes("auth_type"); err == nil {
+		if raw != nil {
+			if parsed, err := strconv.ParseInt(string(raw), 10, 64); err == nil {
+				v := AuthType(parsed)
+				t.Type = v
+				count++
+			}
+		}
+	}
+	if raw, err := res.GetRawBytes("create"); err == nil {
+		if raw != nil {
+			t.Create.FromString(string(raw))
+			count++
+		}
+	}
+	if raw, err := res.GetRawBytes("data"); err == nil {
+		if raw != nil {
+			v := string(raw)
+			t.Data = v
+			count++
+		}
+	}
+	return count
+}
@@ -8,11 +8,12 @@ import (
 	"testing"
 	"time"
 
+	"github.com/denisbdn/dbnames/dbnamestest"
 	_ "github.com/go-sql-driver/mysql"
 )
 
 type Date struct {
-	Time    MYSQLDATETIME `json:"time", omitempty`
+	Time    MYSQLDATETIME `json:"time,omitempty"`
 	TimeStr string        `json:"timeStr"`
 }
 
@@ -121,6 +122,8 @@ func TestMYSQLDATETIME(t *testing.T) {
 	t.Log("now time Zone is", zoneName, zoneOffset)
 }
 
+//go:generate go run ./cmd/dbnames-gen -src $GOFILE
+
 type DBData struct {
 	Crc      uint32        `db:"crc"`
 	Create   MYSQLDATETIME `db:"create"`
@@ -187,35 +190,40 @@ const (
 const DBAuthTable string = "auth"
 
 /*
-CREATE TABLE `auth` (
-  `user_id` int(10) unsigned NOT NULL,
-  `auth_type` tinyint(4) NOT NULL,
-  `create` datetime NOT NULL,
-  `data` varchar(255) DEFAULT NULL
-) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+таблица создается по структуре: BuildCreateTable(DBAuthTable, DBAuth{}, DIALECTMYSQL)
 
 INSERT INTO `auth` VALUES (184216, 0, NOW(), "{'name': 'Denis'}");
 
 */
 
 type DBAuth struct {
-	UserId UserIdType    `db:"user_id"`
-	Type   AuthType      `db:"auth_type"`
+	UserId UserIdType    `db:"user_id" dbtype:"int(10) unsigned"`
+	Type   AuthType      `db:"auth_type" dbtype:"tinyint(4)"`
 	Create MYSQLDATETIME `db:"create"`
-	Data   string        `db:"data"`
+	Data   string        `db:"data" dbddl:"null,default=NULL"`
+}
+
+func (DBAuth) TableOptions() TableOptions {
+	return TableOptions{Engine: "InnoDB", Charset: "utf8mb4", Collate: "utf8mb4_bin"}
+}
+
+func (DBAuth) TableName() string {
+	return DBAuthTable
 }
 
 func TestFillFromDBData(t *testing.T) {
-	// t.Skip("Need connection to database and created non empty table with defined structure on it")
 	// шаблон запроса
 	fields := BuildFields(DBAuthTable, DBAuth{}, "user_id", "auth_type", "first_begin", "create", "data")
 	query := fmt.Sprintf("SELECT %s FROM `%s` LIMIT 1;", strings.Join(fields, ", "), DBAuthTable)
 
-	db, errOpen := sql.Open("mysql", "test:pass@tcp(127.0.0.1:3306)/mp")
+	// вместо сервера тестовый драйвер с той же строкой что в INSERT выше
+	db, mock, errOpen := dbnamestest.New()
 	if errOpen != nil {
-		t.Error(errOpen)
+		t.Fatal(errOpen)
 	}
 	defer db.Close()
+	mock.ExpectQuery(query).WillReturnRows(dbnamestest.NewRows("user_id", "auth_type", "create", "data").
+		AddRow(184216, 0, "2023-05-12 21:41:23", "{'name': 'Denis'}"))
 
 	rows, errQuery := db.Query(query)
 	if errQuery != nil {
@@ -255,4 +263,121 @@ func TestFillFromDBData(t *testing.T) {
 	t.Log(value)
 	t.Log(str)
 	t.Log(unix)
+	if userId != 184216 || authType != UnauthType || value != "{'name': 'Denis'}" || str != "'2023-05-12 21:41:23'" {
+		t.Errorf("bad data %v", data)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBuildConditionsZero(t *testing.T) {
+	// нулевые поля по умолчанию пропускаются
+	cond1 := BuildConditions(DBAuthTable, DBAuth{UserId: 10}, EQUAL)
+	if len(cond1) != 1 || cond1[0] != " `auth`.`user_id`=10" {
+		t.Errorf("bad conditions %v", cond1)
+	}
+
+	// а явно перечисленные попадают в условие
+	cond2 := BuildConditionsWithZero(DBAuthTable, DBAuth{UserId: 10}, EQUAL, "auth_type", "data")
+	if len(cond2) != 3 || cond2[1] != " `auth`.`auth_type`=0" || cond2[2] != " `auth`.`data`=''" {
+		t.Errorf("bad zero conditions %v", cond2)
+	}
+	t.Log("cond2", cond2)
+
+	// поля-указатели: nil пропускается, нулевое значение по указателю это условие
+	type AuthFilter struct {
+		UserId *UserIdType `db:"user_id"`
+		Type   *AuthType   `db:"auth_type"`
+		Data   *string     `db:"data"`
+	}
+	unauth := UnauthType
+	cond3 := BuildConditions(DBAuthTable, AuthFilter{Type: &unauth}, NOTEQ)
+	if len(cond3) != 1 || cond3[0] != " `auth`.`auth_type`!=0" {
+		t.Errorf("bad pointer conditions %v", cond3)
+	}
+
+	quote := "a\\' OR 1=1"
+	cond4 := BuildConditions(DBAuthTable, AuthFilter{Data: &quote}, EQUAL)
+	if len(cond4) != 1 || cond4[0] != " `auth`.`data`='a\\\\\\' OR 1=1'" {
+		t.Errorf("bad escaped conditions %v", cond4)
+	}
+}
+
+func TestBuildTypedCondition(t *testing.T) {
+	custom := MYSQLDATETIME{}
+	custom.FromString("2023-05-12 21:41:23")
+
+	// time.Time приводится к MYSQLDATETIME и выводится как дата
+	cond1, err1 := BuildTypedCondition(DBAuthTable, DBAuth{Create: custom}, MORE, time.Time(custom))
+	if err1 != nil {
+		t.Error(err1)
+	} else if len(cond1) != 1 || cond1[0] != " `auth`.`create`>'2023-05-12 21:41:23'" {
+		t.Errorf("bad time condition %v", cond1)
+	}
+
+	// строку в число не приводим
+	if _, err := BuildTypedCondition(DBAuthTable, DBAuth{UserId: 1}, EQUAL, "10"); err == nil {
+		t.Errorf("string accepted as user_id")
+	}
+	// BuildCondition не теряет условие, а выводит data как раньше
+	if cond := BuildCondition(DBAuthTable, DBAuth{UserId: 1}, EQUAL, "10"); len(cond) != 1 || cond[0] != " `auth`.`user_id`=10" {
+		t.Errorf("bad untyped condition %v", cond)
+	}
+	// но текст не того типа не становится частью запроса
+	if cond := BuildCondition(DBAuthTable, DBAuth{UserId: 1}, EQUAL, "1 OR 1=1"); len(cond) != 1 || cond[0] != " `auth`.`user_id`='1 OR 1=1'" {
+		t.Errorf("injected untyped condition %v", cond)
+	}
+	if cond := BuildCondition(DBAuthTable, DBAuth{Type: PhoneType}, IN, "1) OR (1"); len(cond) != 1 || cond[0] != " `auth`.`auth_type` IN ('1) OR (1')" {
+		t.Errorf("injected untyped list %v", cond)
+	}
+	// отрицательное число в беззнаковое поле тоже
+	if _, err := BuildTypedCondition(DBAuthTable, DBAuth{UserId: 1}, EQUAL, -1); err == nil {
+		t.Errorf("negative accepted as user_id")
+	}
+
+	cond2, err2 := BuildTypedCondition(DBAuthTable, DBAuth{Type: PhoneType}, IN, []int{1, 2})
+	if err2 != nil {
+		t.Error(err2)
+	} else if len(cond2) != 1 || cond2[0] != " `auth`.`auth_type` IN (1, 2)" {
+		t.Errorf("bad in condition %v", cond2)
+	}
+}
+
+func TestGeneratedFiller(t *testing.T) {
+	if DBAuthFields != strings.Join(BuildFields(DBAuthTable, DBAuth{}), ", ") || DBDataColumns != strings.Join(BuildFields("", DBData{}), ", ") {
+		t.Errorf("generated columns differ from BuildFields")
+	}
+
+	res := &DBResult{
+		values: []sql.RawBytes{[]byte("184216"), []byte("1"), []byte("2023-05-12 21:41:23"), nil},
+		names:  map[string]int{"user_id": 0, "auth_type": 1, "create": 2, "data": 3},
+	}
+	// DBAuth имеет сгенерированный FillFromDBResult, а у reflected того же вида полей его нет
+	type reflected DBAuth
+	generated := DBAuth{}
+	byReflection := reflected{}
+	countGenerated := FillByDBResult(res, &generated)
+	countReflection := FillByDBResult(res, &byReflection)
+	if countGenerated != 3 || countGenerated != countReflection || generated != DBAuth(byReflection) {
+		t.Errorf("generated filler differs %d %v, %d %v", countGenerated, generated, countReflection, byReflection)
+	}
+
+	// поле которое не разобралось пропускается в обоих вариантах, остальные заполняются
+	res.values[0] = []byte("-1")
+	generated = DBAuth{}
+	byReflection = reflected{}
+	countGenerated = FillByDBResult(res, &generated)
+	countReflection = FillByDBResult(res, &byReflection)
+	if countGenerated != 2 || countGenerated != countReflection || generated != DBAuth(byReflection) || generated.UserId != 0 {
+		t.Errorf("bad user_id handled differently %d %v, %d %v", countGenerated, generated, countReflection, byReflection)
+	}
+	// bool из tinyint(1)
+	flags := struct {
+		Active bool `db:"active"`
+	}{}
+	flagsRes := &DBResult{values: []sql.RawBytes{[]byte("1")}, names: map[string]int{"active": 0}}
+	if count := FillByDBResult(flagsRes, &flags); count != 1 || !flags.Active {
+		t.Errorf("bad bool %d %v", count, flags)
+	}
 }
@@ -0,0 +1,526 @@
+/*
+пакет содержит драйвер database/sql для тестов без сервера базы: тест описывает
+ожидаемые запросы (точно или регулярным выражением), их параметры и результаты,
+драйвер отвечает по этому сценарию и запоминает все отклонения
+
+	db, mock, err := dbnamestest.New()
+	mock.ExpectQuery("SELECT `user_id` FROM `auth` WHERE `user_id` = ?").
+		WithArgs(184216).
+		WillReturnRows(dbnamestest.NewRows("user_id").AddRow(184216))
+	...
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+*/
+package dbnamestest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// имя драйвера для sql.Open
+const DriverName = "dbnamestest"
+
+var theDriver = &fakeDriver{mocks: make(map[string]*Mock)}
+
+func init() {
+	sql.Register(DriverName, theDriver)
+}
+
+const (
+	kindQuery = iota
+	kindExec
+	kindBegin
+	kindCommit
+	kindRollback
+)
+
+var kindNames = []string{"query", "exec", "begin", "commit", "rollback"}
+
+/*
+ожидаемое обращение к базе, настраивается цепочкой методов
+*/
+type Expectation struct {
+	kind         int
+	query        string
+	pattern      *regexp.Regexp
+	args         []interface{}
+	checkArgs    bool
+	rows         *Rows
+	lastInsertId int64
+	rowsAffected int64
+	err          error
+	delay        time.Duration
+	done         bool
+}
+
+/*
+ожидаемые параметры запроса, nil это NULL, AnyArg подходит к любому значению
+*/
+func (e *Expectation) WithArgs(args ...interface{}) *Expectation {
+	e.args = args
+	e.checkArgs = true
+	return e
+}
+
+func (e *Expectation) WillReturnRows(rows *Rows) *Expectation {
+	e.rows = rows
+	return e
+}
+
+func (e *Expectation) WillReturnResult(lastInsertId int64, rowsAffected int64) *Expectation {
+	e.lastInsertId = lastInsertId
+	e.rowsAffected = rowsAffected
+	return e
+}
+
+func (e *Expectation) WillReturnError(err error) *Expectation {
+	e.err = err
+	return e
+}
+
+/*
+ответ задерживается на d, если контекст отменят раньше то вернется его ошибка
+*/
+func (e *Expectation) WillDelayFor(d time.Duration) *Expectation {
+	e.delay = d
+	return e
+}
+
+func (e *Expectation) String() string {
+	res := kindNames[e.kind]
+	if e.pattern != nil {
+		res += fmt.Sprintf(" matching %q", e.pattern.String())
+	} else if len(e.query) > 0 {
+		res += fmt.Sprintf(" %q", e.query)
+	}
+	if e.checkArgs {
+		res += fmt.Sprintf(" with args %v", e.args)
+	}
+	return res
+}
+
+/*
+параметр который подходит к любому значению
+*/
+type anyArg struct{}
+
+func AnyArg() interface{} {
+	return anyArg{}
+}
+
+/*
+результат запроса: столбцы и строки, nil в строке это NULL
+*/
+type Rows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func NewRows(columns ...string) *Rows {
+	return &Rows{columns: columns}
+}
+
+func (r *Rows) AddRow(values ...interface{}) *Rows {
+	if len(values) != len(r.columns) {
+		panic(fmt.Sprintf("dbnamestest: row has %d values for %d columns", len(values), len(r.columns)))
+	}
+	row := make([]driver.Value, len(values))
+	for i, value := range values {
+		converted, err := convert(value)
+		if err != nil {
+			panic(fmt.Sprintf("dbnamestest: column %s: %v", r.columns[i], err))
+		}
+		row[i] = converted
+	}
+	r.values = append(r.values, row)
+	return r
+}
+
+/*
+сценарий одной тестовой базы, ожидания проверяются по порядку
+*/
+type Mock struct {
+	mu           sync.Mutex
+	expectations []*Expectation
+	failures     []string
+	prepared     int
+	closed       int
+}
+
+/*
+сколько раз драйвер готовил выражения (Prepare) и сколько из них закрыто,
+нужно для проверки кеширования выражений
+*/
+func (m *Mock) Statements() (prepared int, closed int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.prepared, m.closed
+}
+
+/*
+функция создает новую тестовую базу со своим сценарием
+*/
+func New() (*sql.DB, *Mock, error) {
+	mock := &Mock{}
+	theDriver.mu.Lock()
+	theDriver.n++
+	dsn := fmt.Sprintf("mock-%d", theDriver.n)
+	theDriver.mocks[dsn] = mock
+	theDriver.mu.Unlock()
+	db, err := sql.Open(DriverName, dsn)
+	if err != nil {
+		return nil, nil, err
+	}
+	return db, mock, nil
+}
+
+func (m *Mock) expect(kind int, query string, pattern string) *Expectation {
+	e := &Expectation{kind: kind, query: normalize(query)}
+	if len(pattern) > 0 {
+		e.pattern = regexp.MustCompile(pattern)
+	}
+	m.mu.Lock()
+	m.expectations = append(m.expectations, e)
+	m.mu.Unlock()
+	return e
+}
+
+/*
+ожидается запрос с таким текстом, пробелы и переводы строк сравниваются как один пробел
+*/
+func (m *Mock) ExpectQuery(query string) *Expectation {
+	return m.expect(kindQuery, query, "")
+}
+
+/*
+ожидается запрос текст которого подходит под регулярное выражение
+*/
+func (m *Mock) ExpectQueryRegexp(pattern string) *Expectation {
+	return m.expect(kindQuery, "", pattern)
+}
+
+func (m *Mock) ExpectExec(query string) *Expectation {
+	return m.expect(kindExec, query, "")
+}
+
+func (m *Mock) ExpectExecRegexp(pattern string) *Expectation {
+	return m.expect(kindExec, "", pattern)
+}
+
+func (m *Mock) ExpectBegin() *Expectation {
+	return m.expect(kindBegin, "", "")
+}
+
+func (m *Mock) ExpectCommit() *Expectation {
+	return m.expect(kindCommit, "", "")
+}
+
+func (m *Mock) ExpectRollback() *Expectation {
+	return m.expect(kindRollback, "", "")
+}
+
+/*
+ошибка если какие-то ожидания не выполнены или были неожиданные обращения
+*/
+func (m *Mock) ExpectationsWereMet() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	problems := append([]string{}, m.failures...)
+	for _, e := range m.expectations {
+		if !e.done {
+			problems = append(problems, "not called: "+e.String())
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("dbnamestest: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+/*
+находит следующее невыполненное ожидание и сверяет его с обращением
+*/
+func (m *Mock) match(kind int, query string, args []driver.NamedValue) (*Expectation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var next *Expectation
+	for _, e := range m.expectations {
+		if !e.done {
+			next = e
+			break
+		}
+	}
+	call := kindNames[kind]
+	if len(query) > 0 {
+		call += fmt.Sprintf(" %q", query)
+	}
+	fail := func(reason string) error {
+		m.failures = append(m.failures, reason)
+		return fmt.Errorf("dbnamestest: %s", reason)
+	}
+	if next == nil {
+		return nil, fail("unexpected " + call)
+	}
+	if next.kind != kind {
+		return nil, fail(fmt.Sprintf("unexpected %s, expected %s", call, next))
+	}
+	normalized := normalize(query)
+	if next.pattern != nil && !next.pattern.MatchString(normalized) {
+		return nil, fail(fmt.Sprintf("unexpected %s, expected %s", call, next))
+	}
+	if next.pattern == nil && (kind == kindQuery || kind == kindExec) && next.query != normalized {
+		return nil, fail(fmt.Sprintf("unexpected %s, expected %s", call, next))
+	}
+	if next.checkArgs {
+		if err := matchArgs(next.args, args); err != nil {
+			return nil, fail(fmt.Sprintf("%s: %v", call, err))
+		}
+	}
+	next.done = true
+	return next, nil
+}
+
+func matchArgs(expected []interface{}, args []driver.NamedValue) error {
+	if len(expected) != len(args) {
+		return fmt.Errorf("expected %d args, got %d", len(expected), len(args))
+	}
+	for i, e := range expected {
+		if _, ok := e.(anyArg); ok {
+			continue
+		}
+		value, err := convert(e)
+		if err != nil {
+			return err
+		}
+		if !reflect.DeepEqual(value, args[i].Value) {
+			return fmt.Errorf("arg %d: expected %#v, got %#v", i+1, value, args[i].Value)
+		}
+	}
+	return nil
+}
+
+/*
+приводит значение к виду драйвера, строки храним как []byte как это делают настоящие драйверы
+*/
+func convert(value interface{}) (driver.Value, error) {
+	converted, err := driver.DefaultParameterConverter.ConvertValue(value)
+	if err != nil {
+		return nil, err
+	}
+	if s, ok := converted.(string); ok {
+		return []byte(s), nil
+	}
+	return converted, nil
+}
+
+/*
+пробелы, табуляции и переводы строк сводятся к одному пробелу
+*/
+func normalize(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
+
+func wait(ctx context.Context, e *Expectation) error {
+	if e.delay > 0 {
+		timer := time.NewTimer(e.delay)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return e.err
+}
+
+// дальше реализация интерфейсов database/sql/driver
+
+type fakeDriver struct {
+	mu    sync.Mutex
+	mocks map[string]*Mock
+	n     int
+}
+
+func (d *fakeDriver) Open(dsn string) (driver.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	mock, ok := d.mocks[dsn]
+	if !ok {
+		return nil, fmt.Errorf("dbnamestest: unknown dsn %q, use dbnamestest.New", dsn)
+	}
+	return &fakeConn{mock: mock}, nil
+}
+
+type fakeConn struct {
+	mock *Mock
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *fakeConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	c.mock.mu.Lock()
+	c.mock.prepared++
+	c.mock.mu.Unlock()
+	return &fakeStmt{conn: c, query: query}, nil
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *fakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	e, err := c.mock.match(kindBegin, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := wait(ctx, e); err != nil {
+		return nil, err
+	}
+	return &fakeTx{conn: c}, nil
+}
+
+func (c *fakeConn) CheckNamedValue(value *driver.NamedValue) error {
+	converted, err := convert(value.Value)
+	if err != nil {
+		// свои типы оставляем как есть, их сверяет WithArgs
+		return nil
+	}
+	value.Value = converted
+	return nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	e, err := c.mock.match(kindQuery, query, args)
+	if err != nil {
+		return nil, err
+	}
+	if err := wait(ctx, e); err != nil {
+		return nil, err
+	}
+	if e.rows == nil {
+		return &fakeRows{rows: &Rows{}}, nil
+	}
+	return &fakeRows{rows: e.rows}, nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	e, err := c.mock.match(kindExec, query, args)
+	if err != nil {
+		return nil, err
+	}
+	if err := wait(ctx, e); err != nil {
+		return nil, err
+	}
+	return fakeResult{lastInsertId: e.lastInsertId, rowsAffected: e.rowsAffected}, nil
+}
+
+type fakeResult struct {
+	lastInsertId int64
+	rowsAffected int64
+}
+
+func (r fakeResult) LastInsertId() (int64, error) {
+	return r.lastInsertId, nil
+}
+
+func (r fakeResult) RowsAffected() (int64, error) {
+	return r.rowsAffected, nil
+}
+
+type fakeTx struct {
+	conn *fakeConn
+}
+
+func (tx *fakeTx) Commit() error {
+	e, err := tx.conn.mock.match(kindCommit, "", nil)
+	if err != nil {
+		return err
+	}
+	return e.err
+}
+
+func (tx *fakeTx) Rollback() error {
+	e, err := tx.conn.mock.match(kindRollback, "", nil)
+	if err != nil {
+		return err
+	}
+	return e.err
+}
+
+type fakeStmt struct {
+	conn  *fakeConn
+	query string
+}
+
+func (s *fakeStmt) Close() error {
+	s.conn.mock.mu.Lock()
+	s.conn.mock.closed++
+	s.conn.mock.mu.Unlock()
+	return nil
+}
+
+func (s *fakeStmt) NumInput() int {
+	return -1
+}
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), named(args))
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), named(args))
+}
+
+func (s *fakeStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.conn.ExecContext(ctx, s.query, args)
+}
+
+func (s *fakeStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.conn.QueryContext(ctx, s.query, args)
+}
+
+func named(args []driver.Value) []driver.NamedValue {
+	res := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		res[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return res
+}
+
+type fakeRows struct {
+	rows *Rows
+	pos  int
+}
+
+func (r *fakeRows) Columns() []string {
+	return r.rows.columns
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.rows.values) {
+		return io.EOF
+	}
+	copy(dest, r.rows.values[r.pos])
+	r.pos++
+	return nil
+}
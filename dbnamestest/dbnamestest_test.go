@@ -0,0 +1,100 @@
+package dbnamestest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMock(t *testing.T) {
+	db, mock, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT `user_id`, `data`\n  FROM `auth` WHERE `user_id` > ?").
+		WithArgs(10).
+		WillReturnRows(NewRows("user_id", "data").AddRow(11, "x").AddRow(12, nil))
+	mock.ExpectBegin()
+	mock.ExpectExecRegexp("^UPDATE `auth` SET").WithArgs("y", AnyArg()).WillReturnResult(0, 2)
+	mock.ExpectCommit()
+
+	rows, err := db.Query("SELECT `user_id`, `data` FROM `auth` WHERE `user_id` > ?", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]int64, 0)
+	nulls := 0
+	for rows.Next() {
+		var id int64
+		var data *string
+		if err := rows.Scan(&id, &data); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+		if data == nil {
+			nulls++
+		}
+	}
+	rows.Close()
+	if len(ids) != 2 || ids[1] != 12 || nulls != 1 {
+		t.Errorf("bad rows %v %d", ids, nulls)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := tx.Exec("UPDATE `auth` SET `data` = ? WHERE `user_id` = ?", "y", 11)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if affected, _ := res.RowsAffected(); affected != 2 {
+		t.Errorf("bad rows affected %d", affected)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMockFailures(t *testing.T) {
+	db, mock, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	boom := errors.New("boom")
+	mock.ExpectExec("DELETE FROM `auth`").WillReturnError(boom)
+	mock.ExpectQuery("SELECT 1").WithArgs(1)
+	mock.ExpectQuery("SELECT SLEEP(1)").WillDelayFor(time.Second)
+	mock.ExpectExec("never called")
+
+	if _, err := db.Exec("DELETE FROM `auth`"); !errors.Is(err, boom) {
+		t.Errorf("bad error %v", err)
+	}
+	if _, err := db.Query("SELECT 1", 2); err == nil {
+		t.Errorf("bad args accepted")
+	}
+	// неудачная попытка ожидание не закрывает
+	if rows, err := db.Query("SELECT 1", 1); err != nil {
+		t.Error(err)
+	} else {
+		rows.Close()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := db.QueryContext(ctx, "SELECT SLEEP(1)"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("bad delayed error %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err == nil {
+		t.Errorf("failures not reported")
+	} else {
+		t.Log(err)
+	}
+}
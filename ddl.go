@@ -0,0 +1,478 @@
+package dbnames
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+/*
+диалект sql: от него зависят кавычки у имен, типы столбцов и прочий синтаксис,
+нулевое значение это MySQL
+*/
+type Dialect int
+
+const (
+	DIALECTMYSQL Dialect = iota
+	DIALECTPOSTGRES
+	DIALECTSQLITE
+)
+
+func (d Dialect) String() string {
+	switch d {
+	case DIALECTMYSQL:
+		return "mysql"
+	case DIALECTPOSTGRES:
+		return "postgres"
+	case DIALECTSQLITE:
+		return "sqlite"
+	}
+	return fmt.Sprintf("dialect(%d)", int(d))
+}
+
+/*
+имя таблицы или столбца в кавычках диалекта: `name` для MySQL и "name" для остальных
+*/
+func (d Dialect) Quote(name string) string {
+	if d == DIALECTMYSQL {
+		return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+/*
+параметр запроса с номером n (с единицы): ? для MySQL и SQLite, $n для postgres
+*/
+func (d Dialect) Placeholder(n int) string {
+	if d == DIALECTPOSTGRES {
+		return fmt.Sprintf("$%d", n)
+	}
+	return "?"
+}
+
+/*
+выражения для точек сохранения вложенных транзакций, в SQLite слово SAVEPOINT
+после ROLLBACK TO и RELEASE не пишется
+*/
+func (d Dialect) Savepoint(name string) string {
+	return "SAVEPOINT " + d.Quote(name)
+}
+
+func (d Dialect) RollbackToSavepoint(name string) string {
+	if d == DIALECTSQLITE {
+		return "ROLLBACK TO " + d.Quote(name)
+	}
+	return "ROLLBACK TO SAVEPOINT " + d.Quote(name)
+}
+
+func (d Dialect) ReleaseSavepoint(name string) string {
+	if d == DIALECTSQLITE {
+		return "RELEASE " + d.Quote(name)
+	}
+	return "RELEASE SAVEPOINT " + d.Quote(name)
+}
+
+// эта часть описывает схему таблицы и генерирует по ней CREATE TABLE
+
+/*
+описание столбца, Type в синтаксисе диалекта, например INT UNSIGNED или VARCHAR(255)
+Default это sql выражение как есть (строки должны быть в кавычках)
+*/
+type Column struct {
+	Name          string
+	Type          string
+	Null          bool
+	Default       *string
+	AutoIncrement bool
+}
+
+type Index struct {
+	Name    string
+	Columns []string
+	Unique  bool
+}
+
+/*
+описание таблицы, Engine, Charset и Collate используются только для MySQL,
+IfNotExists добавляет IF NOT EXISTS в CreateSQL
+*/
+type Table struct {
+	Name        string
+	Columns     []Column
+	PrimaryKey  []string
+	Indexes     []Index
+	Engine      string
+	Charset     string
+	Collate     string
+	IfNotExists bool
+}
+
+/*
+параметры таблицы которые нельзя описать тегами одного поля
+*/
+type TableOptions struct {
+	Engine  string
+	Charset string
+	Collate string
+	Indexes []Index
+}
+
+/*
+структура может описать параметры своей таблицы и составные индексы
+
+	func (DBAuth) TableOptions() TableOptions {
+		return TableOptions{Engine: "InnoDB", Charset: "utf8mb4", Collate: "utf8mb4_bin",
+			Indexes: []Index{{Name: "idx_type_create", Columns: []string{"auth_type", "create"}}}}
+	}
+*/
+type TableOptionsInterface interface {
+	TableOptions() TableOptions
+}
+
+/*
+функция строит описание таблицы table по структуре model. Каждое поле с тегом db это
+столбец, тип выводится из типа поля (см columnType) или задается тегом dbtype,
+дополнительные свойства задаются тегом dbddl через запятую:
+
+	pk         - первичный ключ (несколько полей с pk дают составной ключ)
+	null       - столбец может быть NULL (для полей-указателей это по умолчанию)
+	index      - индекс idx_<столбец>
+	unique     - уникальный индекс uniq_<столбец>
+	autoinc    - AUTO_INCREMENT
+	default=.. - значение по умолчанию, sql выражение, запятые внутри кавычек
+	             и скобок не разделяют опции: default='a,b', default=(concat('a', 'b'))
+
+	type DBAuth struct {
+		UserId UserIdType    `db:"user_id" dbddl:"pk"`
+		Type   AuthType      `db:"auth_type" dbtype:"tinyint(4)"`
+		Create MYSQLDATETIME `db:"create" dbddl:"index"`
+		Data   string        `db:"data" dbddl:"null"`
+	}
+*/
+func TableFromStruct(table string, model interface{}, dialect Dialect) (*Table, error) {
+	modelType := reflect.TypeOf(model)
+	if modelType.Kind() == reflect.Pointer {
+		modelType = modelType.Elem()
+	}
+	if modelType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("need struct, got %s", modelType)
+	}
+	table = resolveTable(table, model)
+	if len(table) == 0 {
+		return nil, fmt.Errorf("no table name for %s", modelType)
+	}
+	res := &Table{Name: table}
+	for i := 0; i < modelType.NumField(); i++ {
+		field := modelType.Field(i)
+		dbFieldName, find := field.Tag.Lookup("db")
+		if !find {
+			continue
+		}
+		column := Column{Name: dbFieldName}
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+			column.Null = true
+		}
+		if dbType, ok := field.Tag.Lookup("dbtype"); ok {
+			column.Type = dbType
+		} else {
+			dbType, err := columnType(fieldType, dialect)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", dbFieldName, err)
+			}
+			column.Type = dbType
+		}
+		if options, ok := field.Tag.Lookup("dbddl"); ok {
+			for _, option := range splitDDLOptions(options) {
+				switch {
+				case option == "":
+				case option == "pk":
+					res.PrimaryKey = append(res.PrimaryKey, dbFieldName)
+				case option == "null":
+					column.Null = true
+				case option == "index":
+					res.Indexes = append(res.Indexes, Index{Name: "idx_" + dbFieldName, Columns: []string{dbFieldName}})
+				case option == "unique":
+					res.Indexes = append(res.Indexes, Index{Name: "uniq_" + dbFieldName, Columns: []string{dbFieldName}, Unique: true})
+				case option == "autoinc":
+					column.AutoIncrement = true
+				case strings.HasPrefix(option, "default="):
+					value := strings.TrimPrefix(option, "default=")
+					column.Default = &value
+				default:
+					return nil, fmt.Errorf("field %s: unknown dbddl option %q", dbFieldName, option)
+				}
+			}
+		}
+		res.Columns = append(res.Columns, column)
+	}
+	if len(res.Columns) == 0 {
+		return nil, fmt.Errorf("%s has no db fields", modelType)
+	}
+	if options, ok := reflect.New(modelType).Interface().(TableOptionsInterface); ok {
+		opts := options.TableOptions()
+		res.Engine = opts.Engine
+		res.Charset = opts.Charset
+		res.Collate = opts.Collate
+		res.Indexes = append(res.Indexes, opts.Indexes...)
+	}
+	for _, index := range res.Indexes {
+		for _, name := range index.Columns {
+			if res.Column(name) == nil {
+				return nil, fmt.Errorf("index %s: unknown column %s", index.Name, name)
+			}
+		}
+	}
+	return res, nil
+}
+
+/*
+функция выдает CREATE TABLE для структуры model, см TableFromStruct
+*/
+func BuildCreateTable(table string, model interface{}, dialect Dialect) (string, error) {
+	res, err := TableFromStruct(table, model, dialect)
+	if err != nil {
+		return "", err
+	}
+	return res.CreateSQL(dialect), nil
+}
+
+/*
+выдает столбец по имени или nil
+*/
+func (t *Table) Column(name string) *Column {
+	for i := range t.Columns {
+		if t.Columns[i].Name == name {
+			return &t.Columns[i]
+		}
+	}
+	return nil
+}
+
+/*
+описание столбца для CREATE TABLE и ALTER TABLE
+*/
+func (c *Column) Definition(dialect Dialect) string {
+	def := dialect.Quote(c.Name) + " " + c.Type
+	if c.AutoIncrement && dialect == DIALECTPOSTGRES {
+		def += " GENERATED BY DEFAULT AS IDENTITY"
+	}
+	if c.Null {
+		def += " NULL"
+	} else {
+		def += " NOT NULL"
+	}
+	if c.Default != nil {
+		def += " DEFAULT " + *c.Default
+	}
+	if c.AutoIncrement && dialect == DIALECTMYSQL {
+		def += " AUTO_INCREMENT"
+	}
+	return def
+}
+
+/*
+выдает CREATE TABLE, для MySQL индексы описываются в самой таблице,
+для остальных диалектов добавляются отдельные CREATE INDEX
+*/
+func (t *Table) CreateSQL(dialect Dialect) string {
+	lines := make([]string, 0, len(t.Columns)+len(t.Indexes)+1)
+	// в SQLite автоинкремент возможен только у INTEGER PRIMARY KEY описанного в самом столбце
+	inlinePK := dialect == DIALECTSQLITE && len(t.PrimaryKey) == 1
+	for _, column := range t.Columns {
+		def := column.Definition(dialect)
+		if inlinePK && column.Name == t.PrimaryKey[0] {
+			def += " PRIMARY KEY"
+			if column.AutoIncrement {
+				def += " AUTOINCREMENT"
+			}
+		}
+		lines = append(lines, "  "+def)
+	}
+	if len(t.PrimaryKey) > 0 && !inlinePK {
+		lines = append(lines, "  PRIMARY KEY ("+quoteColumns(dialect, t.PrimaryKey)+")")
+	}
+	if dialect == DIALECTMYSQL {
+		for _, index := range t.Indexes {
+			lines = append(lines, "  "+index.definition(dialect))
+		}
+	}
+	ifNotExists := ""
+	if t.IfNotExists {
+		ifNotExists = "IF NOT EXISTS "
+	}
+	var sb strings.Builder
+	sb.WriteString("CREATE TABLE " + ifNotExists + dialect.Quote(t.Name) + " (\n")
+	sb.WriteString(strings.Join(lines, ",\n"))
+	sb.WriteString("\n)")
+	if dialect == DIALECTMYSQL {
+		if len(t.Engine) > 0 {
+			sb.WriteString(" ENGINE=" + t.Engine)
+		}
+		if len(t.Charset) > 0 {
+			sb.WriteString(" DEFAULT CHARSET=" + t.Charset)
+		}
+		if len(t.Collate) > 0 {
+			sb.WriteString(" COLLATE=" + t.Collate)
+		}
+	}
+	sb.WriteString(";")
+	if dialect != DIALECTMYSQL {
+		for _, index := range t.Indexes {
+			sb.WriteString("\n" + index.createSQL(dialect, t.Name, t.IfNotExists) + ";")
+		}
+	}
+	return sb.String()
+}
+
+func (i *Index) definition(dialect Dialect) string {
+	if i.Unique {
+		return "UNIQUE KEY " + dialect.Quote(i.Name) + " (" + quoteColumns(dialect, i.Columns) + ")"
+	}
+	return "KEY " + dialect.Quote(i.Name) + " (" + quoteColumns(dialect, i.Columns) + ")"
+}
+
+func (i *Index) createSQL(dialect Dialect, table string, ifNotExists bool) string {
+	unique := ""
+	if i.Unique {
+		unique = "UNIQUE "
+	}
+	exists := ""
+	if ifNotExists {
+		exists = "IF NOT EXISTS "
+	}
+	return "CREATE " + unique + "INDEX " + exists + dialect.Quote(i.Name) + " ON " + dialect.Quote(table) + " (" + quoteColumns(dialect, i.Columns) + ")"
+}
+
+func quoteColumns(dialect Dialect, columns []string) string {
+	quoted := make([]string, 0, len(columns))
+	for _, column := range columns {
+		quoted = append(quoted, dialect.Quote(column))
+	}
+	return strings.Join(quoted, ", ")
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	mysqlDateTimeType = reflect.TypeOf(MYSQLDATETIME{})
+	bytesType         = reflect.TypeOf([]byte(nil))
+)
+
+/*
+внутрення функция пакета, выводит тип столбца из типа поля
+*/
+func columnType(fieldType reflect.Type, dialect Dialect) (string, error) {
+	if fieldType == timeType || fieldType == mysqlDateTimeType {
+		if dialect == DIALECTPOSTGRES {
+			return "TIMESTAMP", nil
+		}
+		return "DATETIME", nil
+	}
+	if fieldType.Kind() == reflect.Slice && fieldType.Elem().Kind() == reflect.Uint8 {
+		if dialect == DIALECTPOSTGRES {
+			return "BYTEA", nil
+		}
+		return "BLOB", nil
+	}
+	var types map[reflect.Kind]string
+	switch dialect {
+	case DIALECTMYSQL:
+		types = mysqlTypes
+	case DIALECTPOSTGRES:
+		types = postgresTypes
+	case DIALECTSQLITE:
+		types = sqliteTypes
+	default:
+		return "", fmt.Errorf("unknown dialect %s", dialect)
+	}
+	if dbType, ok := types[fieldType.Kind()]; ok {
+		return dbType, nil
+	}
+	return "", fmt.Errorf("no column type for %s, use dbtype tag", fieldType)
+}
+
+var mysqlTypes = map[reflect.Kind]string{
+	reflect.Bool:    "TINYINT(1)",
+	reflect.Int8:    "TINYINT",
+	reflect.Int16:   "SMALLINT",
+	reflect.Int32:   "INT",
+	reflect.Int:     "BIGINT",
+	reflect.Int64:   "BIGINT",
+	reflect.Uint8:   "TINYINT UNSIGNED",
+	reflect.Uint16:  "SMALLINT UNSIGNED",
+	reflect.Uint32:  "INT UNSIGNED",
+	reflect.Uint:    "BIGINT UNSIGNED",
+	reflect.Uint64:  "BIGINT UNSIGNED",
+	reflect.Float32: "FLOAT",
+	reflect.Float64: "DOUBLE",
+	reflect.String:  "VARCHAR(255)",
+}
+
+// в postgres нет беззнаковых, поэтому берем тип побольше
+var postgresTypes = map[reflect.Kind]string{
+	reflect.Bool:    "BOOLEAN",
+	reflect.Int8:    "SMALLINT",
+	reflect.Int16:   "SMALLINT",
+	reflect.Int32:   "INTEGER",
+	reflect.Int:     "BIGINT",
+	reflect.Int64:   "BIGINT",
+	reflect.Uint8:   "SMALLINT",
+	reflect.Uint16:  "INTEGER",
+	reflect.Uint32:  "BIGINT",
+	reflect.Uint:    "NUMERIC(20)",
+	reflect.Uint64:  "NUMERIC(20)",
+	reflect.Float32: "REAL",
+	reflect.Float64: "DOUBLE PRECISION",
+	reflect.String:  "VARCHAR(255)",
+}
+
+var sqliteTypes = map[reflect.Kind]string{
+	reflect.Bool:    "INTEGER",
+	reflect.Int8:    "INTEGER",
+	reflect.Int16:   "INTEGER",
+	reflect.Int32:   "INTEGER",
+	reflect.Int:     "INTEGER",
+	reflect.Int64:   "INTEGER",
+	reflect.Uint8:   "INTEGER",
+	reflect.Uint16:  "INTEGER",
+	reflect.Uint32:  "INTEGER",
+	reflect.Uint:    "INTEGER",
+	reflect.Uint64:  "INTEGER",
+	reflect.Float32: "REAL",
+	reflect.Float64: "REAL",
+	reflect.String:  "TEXT",
+}
+
+/*
+внутрення функция пакета, делит тег dbddl по запятым вне строк в кавычках и скобок,
+чтобы default='a,b' остался одной опцией, пробелы по краям опций убираются
+*/
+func splitDDLOptions(tag string) []string {
+	options := make([]string, 0)
+	depth := 0
+	var quote byte
+	start := 0
+	for i := 0; i < len(tag); i++ {
+		c := tag[i]
+		switch {
+		case quote != 0 && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')' && depth > 0:
+			depth--
+		case c == ',' && depth == 0:
+			options = append(options, strings.TrimSpace(tag[start:i]))
+			start = i + 1
+		}
+	}
+	return append(options, strings.TrimSpace(tag[start:]))
+}
@@ -0,0 +1,84 @@
+package dbnames
+
+import (
+	"testing"
+)
+
+func TestBuildCreateTable(t *testing.T) {
+	ddl, err := BuildCreateTable(DBAuthTable, DBAuth{}, DIALECTMYSQL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "CREATE TABLE `auth` (\n" +
+		"  `user_id` int(10) unsigned NOT NULL,\n" +
+		"  `auth_type` tinyint(4) NOT NULL,\n" +
+		"  `create` DATETIME NOT NULL,\n" +
+		"  `data` VARCHAR(255) NULL DEFAULT NULL\n" +
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;"
+	if ddl != expected {
+		t.Errorf("bad ddl\n%s\n%s", ddl, expected)
+	}
+
+	type DBSession struct {
+		Id      uint64         `db:"id" dbddl:"pk,autoinc"`
+		UserId  uint32         `db:"user_id" dbddl:"index"`
+		Token   string         `db:"token" dbtype:"varchar(64)" dbddl:"unique"`
+		Expire  *MYSQLDATETIME `db:"expire"`
+		Counter int            `db:"counter" dbddl:"default=0"`
+		Ignored string
+	}
+	mysql, err := BuildCreateTable("session", DBSession{}, DIALECTMYSQL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = "CREATE TABLE `session` (\n" +
+		"  `id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,\n" +
+		"  `user_id` INT UNSIGNED NOT NULL,\n" +
+		"  `token` varchar(64) NOT NULL,\n" +
+		"  `expire` DATETIME NULL,\n" +
+		"  `counter` BIGINT NOT NULL DEFAULT 0,\n" +
+		"  PRIMARY KEY (`id`),\n" +
+		"  KEY `idx_user_id` (`user_id`),\n" +
+		"  UNIQUE KEY `uniq_token` (`token`)\n" +
+		");"
+	if mysql != expected {
+		t.Errorf("bad mysql ddl\n%s\n%s", mysql, expected)
+	}
+
+	sqlite, err := BuildCreateTable("session", DBSession{}, DIALECTSQLITE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = "CREATE TABLE \"session\" (\n" +
+		"  \"id\" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,\n" +
+		"  \"user_id\" INTEGER NOT NULL,\n" +
+		"  \"token\" varchar(64) NOT NULL,\n" +
+		"  \"expire\" DATETIME NULL,\n" +
+		"  \"counter\" INTEGER NOT NULL DEFAULT 0\n" +
+		");\n" +
+		"CREATE INDEX \"idx_user_id\" ON \"session\" (\"user_id\");\n" +
+		"CREATE UNIQUE INDEX \"uniq_token\" ON \"session\" (\"token\");"
+	if sqlite != expected {
+		t.Errorf("bad sqlite ddl\n%s\n%s", sqlite, expected)
+	}
+
+	// запятая в значении по умолчанию не делит опции
+	type DBTags struct {
+		Tags string `db:"tags" dbtype:"set('a','b')" dbddl:"default='a,b',index"`
+		Name string `db:"name" dbddl:"default=(concat('x', ',')),null"`
+	}
+	tags, err := TableFromStruct("tags", DBTags{}, DIALECTMYSQL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *tags.Columns[0].Default != "'a,b'" || len(tags.Indexes) != 1 || *tags.Columns[1].Default != "(concat('x', ','))" || !tags.Columns[1].Null {
+		t.Errorf("bad defaults %+v", tags)
+	}
+
+	type DBBad struct {
+		Data map[string]string `db:"data"`
+	}
+	if _, err := BuildCreateTable("bad", DBBad{}, DIALECTMYSQL); err == nil {
+		t.Errorf("map field accepted")
+	}
+}
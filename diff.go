@@ -0,0 +1,218 @@
+package dbnames
+
+import (
+	"strings"
+)
+
+// эта часть сравнивает текущую таблицу с желаемой и генерирует ALTER TABLE
+
+/*
+одно изменение схемы, Destructive означает что при выполнении можно потерять
+данные: удаление столбца или изменение его типа
+*/
+type SchemaChange struct {
+	SQL         string
+	Destructive bool
+}
+
+type SchemaDiff struct {
+	Table   string
+	Changes []SchemaChange
+}
+
+/*
+выражения для выполнения по порядку, изменения с потерей данных попадают
+только если destructive равен true
+*/
+func (diff *SchemaDiff) Statements(destructive bool) []string {
+	statements := make([]string, 0, len(diff.Changes))
+	for _, change := range diff.Changes {
+		if change.Destructive && !destructive {
+			continue
+		}
+		statements = append(statements, change.SQL)
+	}
+	return statements
+}
+
+/*
+изменения с потерей данных которые Statements(false) пропускает
+*/
+func (diff *SchemaDiff) Skipped() []SchemaChange {
+	skipped := make([]SchemaChange, 0)
+	for _, change := range diff.Changes {
+		if change.Destructive {
+			skipped = append(skipped, change)
+		}
+	}
+	return skipped
+}
+
+/*
+функция сравнивает текущую таблицу current (например из ParseCreateTable) с желаемой
+desired (например из TableFromStruct) и выдает ALTER TABLE в синтаксисе MySQL в таком порядке:
+удаление измененных индексов и первичного ключа, добавление столбцов, изменение столбцов,
+удаление столбцов, добавление первичного ключа и индексов.
+Переименование столбца выглядит как удаление и добавление.
+*/
+func DiffTables(current *Table, desired *Table) *SchemaDiff {
+	dialect := DIALECTMYSQL
+	diff := &SchemaDiff{Table: desired.Name}
+	alter := "ALTER TABLE " + dialect.Quote(desired.Name) + " "
+	add := func(sql string, destructive bool) {
+		diff.Changes = append(diff.Changes, SchemaChange{SQL: alter + sql + ";", Destructive: destructive})
+	}
+
+	currentIndexes := make(map[string]Index)
+	for _, index := range current.Indexes {
+		currentIndexes[index.Name] = index
+	}
+	desiredIndexes := make(map[string]Index)
+	for _, index := range desired.Indexes {
+		desiredIndexes[index.Name] = index
+	}
+	for _, index := range current.Indexes {
+		if want, ok := desiredIndexes[index.Name]; !ok || !sameIndex(index, want) {
+			add("DROP INDEX "+dialect.Quote(index.Name), false)
+		}
+	}
+	pkChanged := !sameColumns(current.PrimaryKey, desired.PrimaryKey)
+	if pkChanged && len(current.PrimaryKey) > 0 {
+		add("DROP PRIMARY KEY", false)
+	}
+
+	for i, column := range desired.Columns {
+		if current.Column(column.Name) != nil {
+			continue
+		}
+		position := " FIRST"
+		if i > 0 {
+			position = " AFTER " + dialect.Quote(desired.Columns[i-1].Name)
+		}
+		add("ADD COLUMN "+column.Definition(dialect)+position, false)
+	}
+	for _, column := range desired.Columns {
+		have := current.Column(column.Name)
+		if have == nil {
+			continue
+		}
+		typeChanged := normalizeColumnType(have.Type) != normalizeColumnType(column.Type)
+		nullChanged := have.Null != column.Null
+		if typeChanged || nullChanged || !sameDefault(have, &column) || have.AutoIncrement != column.AutoIncrement {
+			add("MODIFY COLUMN "+column.Definition(dialect), typeChanged || (nullChanged && !column.Null))
+		}
+	}
+	for _, column := range current.Columns {
+		if desired.Column(column.Name) == nil {
+			add("DROP COLUMN "+dialect.Quote(column.Name), true)
+		}
+	}
+
+	if pkChanged && len(desired.PrimaryKey) > 0 {
+		add("ADD PRIMARY KEY ("+quoteColumns(dialect, desired.PrimaryKey)+")", false)
+	}
+	for _, index := range desired.Indexes {
+		if have, ok := currentIndexes[index.Name]; !ok || !sameIndex(have, index) {
+			add("ADD "+index.definition(dialect), false)
+		}
+	}
+	return diff
+}
+
+/*
+функция сравнивает дамп таблицы (SHOW CREATE TABLE) со структурой model, см DiffTables
+*/
+func DiffStruct(currentDDL string, table string, model interface{}) (*SchemaDiff, error) {
+	current, err := ParseCreateTable(currentDDL)
+	if err != nil {
+		return nil, err
+	}
+	desired, err := TableFromStruct(table, model, DIALECTMYSQL)
+	if err != nil {
+		return nil, err
+	}
+	return DiffTables(current, desired), nil
+}
+
+func sameColumns(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sameIndex(a Index, b Index) bool {
+	return a.Unique == b.Unique && sameColumns(a.Columns, b.Columns)
+}
+
+/*
+внутрення функция пакета, значение по умолчанию NULL у столбца который может быть
+NULL равно отсутствию значения по умолчанию, ключевые слова и функции (NULL,
+current_timestamp) сравниваются без учета регистра, строки в кавычках точно
+*/
+func sameDefault(a *Column, b *Column) bool {
+	normalize := func(c *Column) string {
+		if c.Default == nil {
+			if c.Null {
+				return "NULL"
+			}
+			return ""
+		}
+		return upperUnquoted(strings.TrimSpace(*c.Default))
+	}
+	return normalize(a) == normalize(b)
+}
+
+/*
+внутрення функция пакета, переводит в верхний регистр все кроме строк в ' и "
+*/
+func upperUnquoted(str string) string {
+	var res strings.Builder
+	var quote byte
+	for i := 0; i < len(str); i++ {
+		c := str[i]
+		switch {
+		case quote == 0 && (c == '\'' || c == '"'):
+			quote = c
+		case quote == 0 && c >= 'a' && c <= 'z':
+			c -= 'a' - 'A'
+		case c == '\\' && i+1 < len(str):
+			res.WriteByte(c)
+			i++
+			c = str[i]
+		case c == quote:
+			quote = 0
+		}
+		res.WriteByte(c)
+	}
+	return res.String()
+}
+
+/*
+внутрення функция пакета, приводит тип к единому виду: нижний регистр, без ширины
+отображения у целых (MySQL 8 ее не показывает), кроме tinyint(1) который обычно bool
+*/
+func normalizeColumnType(columnType string) string {
+	normalized := strings.Join(strings.Fields(strings.ToLower(columnType)), " ")
+	base, _ := parseColumnType(normalized)
+	switch base {
+	case "integer":
+		normalized = "int" + strings.TrimPrefix(normalized, "integer")
+		base = "int"
+	case "bool", "boolean":
+		return "tinyint(1)"
+	}
+	if _, ok := integerBits[base]; ok && !strings.HasPrefix(normalized, "tinyint(1)") {
+		if open := strings.Index(normalized, "("); open >= 0 {
+			if end := strings.Index(normalized, ")"); end > open {
+				normalized = normalized[:open] + normalized[end+1:]
+			}
+		}
+	}
+	return normalized
+}
@@ -0,0 +1,72 @@
+package dbnames
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiffTables(t *testing.T) {
+	// та же таблица - изменений нет
+	same, err := DiffStruct(authCreateTable, DBAuthTable, DBAuth{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(same.Changes) != 0 {
+		t.Errorf("bad changes %v", same.Changes)
+	}
+
+	type DBAuthV2 struct {
+		UserId UserIdType    `db:"user_id" dbddl:"pk"`
+		Type   AuthType      `db:"auth_type" dbtype:"smallint"`
+		Create MYSQLDATETIME `db:"create" dbddl:"index"`
+		Login  string        `db:"login" dbtype:"varchar(64)" dbddl:"unique,default=''"`
+	}
+	diff, err := DiffStruct(authCreateTable, DBAuthTable, DBAuthV2{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"ALTER TABLE `auth` ADD COLUMN `login` varchar(64) NOT NULL DEFAULT '' AFTER `create`;",
+		"ALTER TABLE `auth` MODIFY COLUMN `auth_type` smallint NOT NULL;",
+		"ALTER TABLE `auth` DROP COLUMN `data`;",
+		"ALTER TABLE `auth` ADD PRIMARY KEY (`user_id`);",
+		"ALTER TABLE `auth` ADD KEY `idx_create` (`create`);",
+		"ALTER TABLE `auth` ADD UNIQUE KEY `uniq_login` (`login`);",
+	}
+	if all := diff.Statements(true); strings.Join(all, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("bad statements\n%s", strings.Join(all, "\n"))
+	}
+	// изменение типа и удаление столбца без разрешения не выполняются
+	safe := diff.Statements(false)
+	if len(safe) != 4 || len(diff.Skipped()) != 2 {
+		t.Errorf("bad safe statements\n%s", strings.Join(safe, "\n"))
+	}
+	// int(10) unsigned и INT UNSIGNED это один тип
+	for _, change := range diff.Changes {
+		if strings.Contains(change.SQL, "`user_id` INT") {
+			t.Errorf("display width is a change")
+		}
+	}
+
+	// регистр функций не важен, регистр строки в кавычках важен
+	withDefault := func(value string) *Table {
+		return &Table{Name: "t", Columns: []Column{{Name: "c", Type: "varchar(16)", Default: &value}}}
+	}
+	if diff := DiffTables(withDefault("current_timestamp"), withDefault("CURRENT_TIMESTAMP")); len(diff.Changes) != 0 {
+		t.Errorf("function case is a change %v", diff.Changes)
+	}
+	if diff := DiffTables(withDefault("'active'"), withDefault("'Active'")); len(diff.Changes) != 1 {
+		t.Errorf("literal case is not a change %v", diff.Changes)
+	}
+	if diff := DiffTables(withDefault(`'it\'s'`), withDefault(`'it\'s'`)); len(diff.Changes) != 0 {
+		t.Errorf("escaped quote is a change %v", diff.Changes)
+	}
+
+	// обратно: удаляем индексы и первичный ключ
+	desired, _ := TableFromStruct(DBAuthTable, DBAuthV2{}, DIALECTMYSQL)
+	current, _ := TableFromStruct(DBAuthTable, DBAuth{}, DIALECTMYSQL)
+	back := DiffTables(desired, current)
+	if sql := back.Statements(false); len(sql) < 3 || sql[0] != "ALTER TABLE `auth` DROP INDEX `idx_create`;" || sql[2] != "ALTER TABLE `auth` DROP PRIMARY KEY;" {
+		t.Errorf("bad back statements\n%s", strings.Join(sql, "\n"))
+	}
+}
@@ -0,0 +1,134 @@
+package dbnames
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// номера ошибок MySQL, которые мы умеем классифицировать
+const (
+	mysqlErrLockWaitTimeout = 1205
+	mysqlErrDeadlock        = 1213
+	mysqlErrDuplicateKey    = 1062
+	mysqlErrForeignKey      = 1452
+	mysqlErrConnection      = 2002
+	mysqlErrServerGone      = 2006
+	mysqlErrServerLost      = 2013
+)
+
+// Error ошибка базы с семантическим кодом, исходная ошибка доступна через errors.Unwrap
+type Error struct {
+	Code BD
+	Err  error
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return e.Code.Error()
+	}
+	return e.Code.Error() + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is позволяет писать errors.Is(err, BDERRORUSEREXIST)
+func (e *Error) Is(target error) bool {
+	code, ok := target.(BD)
+	return ok && code == e.Code
+}
+
+func (bd BD) String() string {
+	switch bd {
+	case BDOK:
+		return "ok"
+	case BDERRORINIT:
+		return "init error"
+	case BDERRORLINK:
+		return "link error"
+	case BDERRORPARAM:
+		return "param error"
+	case BDERRORUSERNOTFOUND:
+		return "not found"
+	case BDERRORUSEREXIST:
+		return "already exists"
+	case BDERRORIP:
+		return "ip error"
+	case BDERRORDEADLOCK:
+		return "deadlock"
+	case BDERRORLOCKTIMEOUT:
+		return "lock wait timeout"
+	case BDERRORFOREIGNKEY:
+		return "foreign key violation"
+	default:
+		return fmt.Sprintf("bd error %d", int(bd))
+	}
+}
+
+// Error BD сам является ошибкой, чтобы его можно было возвращать и сравнивать через errors.Is
+func (bd BD) Error() string {
+	return bd.String()
+}
+
+// Classify оборачивает ошибку драйвера в *Error с кодом BD
+// nil возвращается как есть, неизвестные ошибки тоже возвращаются без изменений
+func Classify(err error) error {
+	if err == nil {
+		return nil
+	}
+	var classified *Error
+	if errors.As(err, &classified) {
+		return err
+	}
+	if code, ok := classify(err); ok {
+		return &Error{Code: code, Err: err}
+	}
+	return err
+}
+
+// Code возвращает код BD для ошибки, BDOK для nil и false если ошибка не классифицируется
+func Code(err error) (BD, bool) {
+	if err == nil {
+		return BDOK, true
+	}
+	var classified *Error
+	if errors.As(err, &classified) {
+		return classified.Code, true
+	}
+	var code BD
+	if errors.As(err, &code) {
+		return code, true
+	}
+	return classify(err)
+}
+
+func classify(err error) (BD, bool) {
+	if errors.Is(err, sql.ErrNoRows) {
+		return BDERRORUSERNOTFOUND, true
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) || errors.Is(err, sql.ErrConnDone) {
+		return BDERRORLINK, true
+	}
+	var myErr *mysql.MySQLError
+	if !errors.As(err, &myErr) {
+		return 0, false
+	}
+	switch myErr.Number {
+	case mysqlErrDuplicateKey:
+		return BDERRORUSEREXIST, true
+	case mysqlErrConnection, mysqlErrServerGone, mysqlErrServerLost:
+		return BDERRORLINK, true
+	case mysqlErrDeadlock:
+		return BDERRORDEADLOCK, true
+	case mysqlErrLockWaitTimeout:
+		return BDERRORLOCKTIMEOUT, true
+	case mysqlErrForeignKey:
+		return BDERRORFOREIGNKEY, true
+	}
+	return 0, false
+}
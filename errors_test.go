@@ -0,0 +1,55 @@
+package dbnames
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestClassify(t *testing.T) {
+	cases := []struct {
+		err  error
+		code BD
+	}{
+		{&mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}, BDERRORUSEREXIST},
+		{&mysql.MySQLError{Number: 2006, Message: "MySQL server has gone away"}, BDERRORLINK},
+		{&mysql.MySQLError{Number: 2013}, BDERRORLINK},
+		{&mysql.MySQLError{Number: 1213}, BDERRORDEADLOCK},
+		{&mysql.MySQLError{Number: 1205}, BDERRORLOCKTIMEOUT},
+		{&mysql.MySQLError{Number: 1452}, BDERRORFOREIGNKEY},
+		{fmt.Errorf("select auth: %w", sql.ErrNoRows), BDERRORUSERNOTFOUND},
+		{mysql.ErrInvalidConn, BDERRORLINK},
+	}
+	for _, c := range cases {
+		err := Classify(c.err)
+		if !errors.Is(err, c.code) {
+			t.Errorf("%v is not %v", err, c.code)
+		}
+		if !errors.Is(err, c.err) {
+			t.Errorf("%v lost source %v", err, c.err)
+		}
+		if code, ok := Code(c.err); !ok || code != c.code {
+			t.Errorf("bad code %v for %v", code, c.err)
+		}
+	}
+	if errors.Is(Classify(&mysql.MySQLError{Number: 1062}), BDERRORLINK) {
+		t.Errorf("duplicate key is link error")
+	}
+	other := errors.New("other")
+	if Classify(other) != other || Classify(nil) != nil {
+		t.Errorf("unknown error changed")
+	}
+	if _, ok := Code(other); ok {
+		t.Errorf("unknown error classified")
+	}
+	if code, ok := Code(fmt.Errorf("wrap: %w", BDERRORPARAM)); !ok || code != BDERRORPARAM {
+		t.Errorf("bad code %v", code)
+	}
+	var myErr *mysql.MySQLError
+	if !errors.As(Classify(&mysql.MySQLError{Number: 1213}), &myErr) || myErr.Number != 1213 {
+		t.Errorf("driver error not reachable")
+	}
+}
@@ -0,0 +1,182 @@
+package dbnames
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// эта часть файла выполняет запросы с контекстом, таймаутом и классификацией ошибок
+
+/*
+таймаут запроса Query, QueryRow и Exec если у контекста нет своего дедлайна,
+0 отключает таймаут по умолчанию
+*/
+var DefaultQueryTimeout = 30 * time.Second
+
+/*
+построитель запроса: текст и параметры для него
+*/
+type Builder interface {
+	Build() (string, []interface{}, error)
+}
+
+type rawQuery struct {
+	query string
+	args  []interface{}
+}
+
+func (r rawQuery) Build() (string, []interface{}, error) {
+	return r.query, r.args, nil
+}
+
+/*
+готовый текст запроса как Builder, например из BuildFields и BuildConditions
+*/
+func Raw(query string, args ...interface{}) Builder {
+	return rawQuery{query: query, args: args}
+}
+
+/*
+SELECT для BuildSelect как Builder
+*/
+type SelectQuery struct {
+	Table   string
+	Model   interface{}
+	Dialect Dialect
+	Fields  []string
+	Where   []Predicate
+}
+
+func (s SelectQuery) Build() (string, []interface{}, error) {
+	return BuildSelect(s.Table, s.Model, s.Dialect, s.Fields, s.Where...)
+}
+
+type maxExecutionTimeKey struct{}
+
+// функция возвращает контекст в котором SELECT из Query и QueryRow получают подсказку
+// оптимизатора MySQL MAX_EXECUTION_TIME(ms), сервер сам прервет слишком долгий запрос
+func WithMaxExecutionTime(ctx context.Context, limit time.Duration) context.Context {
+	return context.WithValue(ctx, maxExecutionTimeKey{}, limit)
+}
+
+var (
+	selectPrefix     = regexp.MustCompile(`^(?i)\s*SELECT\b`)
+	hintBlock        = regexp.MustCompile(`^\s*/\*\+((?s).*?)\*/`)
+	maxExecutionHint = regexp.MustCompile(`(?i)MAX_EXECUTION_TIME`)
+)
+
+// внутрення функция пакета, добавляет подсказку MAX_EXECUTION_TIME сразу после SELECT,
+// если после SELECT уже есть блок подсказок "/*+ ... */" то внутрь него (MySQL читает
+// только первый такой блок), запросы с уже заданной в этом блоке подсказкой и не SELECT
+// не меняются, MAX_EXECUTION_TIME в строках и других комментариях не считается
+func addMaxExecutionTime(ctx context.Context, query string) string {
+	limit, ok := ctx.Value(maxExecutionTimeKey{}).(time.Duration)
+	if !ok || limit <= 0 {
+		return query
+	}
+	loc := selectPrefix.FindStringIndex(query)
+	if loc == nil {
+		return query
+	}
+	ms := limit.Milliseconds()
+	if ms == 0 {
+		ms = 1
+	}
+	hint := fmt.Sprintf("MAX_EXECUTION_TIME(%d)", ms)
+	if block := hintBlock.FindStringSubmatchIndex(query[loc[1]:]); block != nil {
+		if maxExecutionHint.MatchString(query[loc[1]+block[2] : loc[1]+block[3]]) {
+			return query
+		}
+		at := loc[1] + block[2]
+		return query[:at] + " " + hint + query[at:]
+	}
+	return query[:loc[1]] + " /*+ " + hint + " */" + query[loc[1]:]
+}
+
+/*
+внутрення функция пакета, таймаут по умолчанию если у контекста нет дедлайна
+*/
+func withDefaultTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok || DefaultQueryTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, DefaultQueryTimeout)
+}
+
+/*
+функция выполняет запрос построителя b, результат надо закрыть через Close (это же
+освобождает таймаут), ошибки классифицированы (см Classify)
+
+	res, err := dbnames.Query(ctx, db, dbnames.Raw(query, args...))
+	if err != nil {
+		return err
+	}
+	defer res.Close()
+	for res.Next() {
+		...
+	}
+	return res.Err()
+*/
+func Query(ctx context.Context, q Querier, b Builder) (*DBResult, error) {
+	query, args, err := b.Build()
+	if err != nil {
+		return nil, &Error{Code: BDERRORPARAM, Err: err}
+	}
+	ctx, cancel := withDefaultTimeout(ctx)
+	rows, err := q.QueryContext(ctx, addMaxExecutionTime(ctx, query), args...)
+	if err != nil {
+		cancel()
+		return nil, Classify(err)
+	}
+	res, err := New(rows)
+	if err != nil {
+		rows.Close()
+		cancel()
+		return nil, Classify(err)
+	}
+	res.cancel = cancel
+	return res, nil
+}
+
+/*
+функция выполняет запрос и заполняет dest (указатель на структуру) первой строкой
+через FillByDBResult, если строк нет то ошибка с кодом BDERRORUSERNOTFOUND
+*/
+func QueryRow(ctx context.Context, q Querier, b Builder, dest interface{}) error {
+	res, err := Query(ctx, q, b)
+	if err != nil {
+		return err
+	}
+	defer res.Close()
+	if !res.Next() {
+		if err := res.Err(); err != nil {
+			return err
+		}
+		return Classify(sql.ErrNoRows)
+	}
+	if err := res.Scan(); err != nil {
+		return Classify(err)
+	}
+	FillByDBResult(res, dest)
+	return nil
+}
+
+/*
+функция выполняет INSERT, UPDATE, DELETE или DDL построителя b, ошибки классифицированы
+*/
+func Exec(ctx context.Context, q Querier, b Builder) (sql.Result, error) {
+	query, args, err := b.Build()
+	if err != nil {
+		return nil, &Error{Code: BDERRORPARAM, Err: err}
+	}
+	ctx, cancel := withDefaultTimeout(ctx)
+	defer cancel()
+	result, err := q.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, Classify(err)
+	}
+	return result, nil
+}
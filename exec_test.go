@@ -0,0 +1,105 @@
+package dbnames
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/denisbdn/dbnames/dbnamestest"
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestQuery(t *testing.T) {
+	db, mock, err := dbnamestest.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	selectAuth := SelectQuery{Table: DBAuthTable, Model: DBAuth{}, Fields: []string{"user_id", "data"}, Where: []Predicate{AuthCols.UserId.Eq(184216)}}
+
+	mock.ExpectQuery("SELECT /*+ MAX_EXECUTION_TIME(1500) */ `auth`.`user_id`, `auth`.`data` FROM `auth` WHERE `auth`.`user_id`=?").
+		WithArgs(184216).WillReturnRows(dbnamestest.NewRows("user_id", "data").AddRow(184216, "a"))
+	res, err := Query(WithMaxExecutionTime(ctx, 1500*time.Millisecond), db, selectAuth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := 0
+	for res.Next() {
+		res.Scan()
+		auth := DBAuth{}
+		FillByDBResult(res, &auth)
+		if auth.UserId != 184216 || auth.Data != "a" {
+			t.Errorf("bad auth %+v", auth)
+		}
+		count++
+	}
+	if err := res.Err(); err != nil || count != 1 {
+		t.Errorf("bad result %d %v", count, err)
+	}
+	res.Close()
+
+	mock.ExpectQuery("SELECT `auth`.`user_id`, `auth`.`data` FROM `auth` WHERE `auth`.`user_id`=?").
+		WithArgs(184216).WillReturnRows(dbnamestest.NewRows("user_id", "data"))
+	auth := DBAuth{}
+	if err := QueryRow(ctx, db, selectAuth, &auth); !errors.Is(err, BDERRORUSERNOTFOUND) {
+		t.Errorf("bad not found %v", err)
+	}
+	mock.ExpectQuery("SELECT `data` FROM `auth` WHERE `user_id` = ?").WithArgs(1).
+		WillReturnRows(dbnamestest.NewRows("data").AddRow("b"))
+	if err := QueryRow(ctx, db, Raw("SELECT `data` FROM `auth` WHERE `user_id` = ?", 1), &auth); err != nil || auth.Data != "b" {
+		t.Errorf("bad row %+v %v", auth, err)
+	}
+
+	mock.ExpectExec("INSERT INTO `auth` (`user_id`) VALUES (?)").WithArgs(1).
+		WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry '1' for key 'PRIMARY'"})
+	if _, err := Exec(ctx, db, Raw("INSERT INTO `auth` (`user_id`) VALUES (?)", 1)); !errors.Is(err, BDERRORUSEREXIST) {
+		t.Errorf("bad exec error %v", err)
+	}
+	if _, err := Exec(ctx, db, SelectQuery{Table: DBAuthTable, Model: DBAuth{}, Where: []Predicate{AuthCols.UserId.In()}}); !errors.Is(err, BDERRORPARAM) {
+		t.Errorf("bad builder error %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+
+	// таймаут по умолчанию действует только без своего дедлайна
+	defaultTimeout := DefaultQueryTimeout
+	defer func() { DefaultQueryTimeout = defaultTimeout }()
+	DefaultQueryTimeout = 20 * time.Millisecond
+	mock.ExpectExec("DELETE FROM `auth`").WillDelayFor(time.Second)
+	if _, err := Exec(ctx, db, Raw("DELETE FROM `auth`")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("default timeout not applied %v", err)
+	}
+	mock.ExpectExec("DELETE FROM `auth`").WillDelayFor(50 * time.Millisecond)
+	own, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	if _, err := Exec(own, db, Raw("DELETE FROM `auth`")); err != nil {
+		t.Errorf("own deadline ignored %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAddMaxExecutionTime(t *testing.T) {
+	ctx := WithMaxExecutionTime(context.Background(), time.Second)
+	cases := map[string]string{
+		"  select 1":                            "  select /*+ MAX_EXECUTION_TIME(1000) */ 1",
+		"UPDATE `auth` SET `data` = ''":         "UPDATE `auth` SET `data` = ''",
+		"SELECT /*+ MAX_EXECUTION_TIME(5) */ 1": "SELECT /*+ MAX_EXECUTION_TIME(5) */ 1",
+		"SELECTED":                              "SELECTED",
+		"SELECT /*+ INDEX(auth user_id) */ 1":   "SELECT /*+ MAX_EXECUTION_TIME(1000) INDEX(auth user_id) */ 1",
+		"SELECT '/*+ MAX_EXECUTION_TIME(5) */'": "SELECT /*+ MAX_EXECUTION_TIME(1000) */ '/*+ MAX_EXECUTION_TIME(5) */'",
+		"SELECT 1 /* max_execution_time */":     "SELECT /*+ MAX_EXECUTION_TIME(1000) */ 1 /* max_execution_time */",
+	}
+	for query, expected := range cases {
+		if hinted := addMaxExecutionTime(ctx, query); hinted != expected {
+			t.Errorf("bad hint %q -> %q", query, hinted)
+		}
+	}
+	if hinted := addMaxExecutionTime(context.Background(), "SELECT 1"); hinted != "SELECT 1" {
+		t.Errorf("hint without limit %s", hinted)
+	}
+}
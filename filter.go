@@ -0,0 +1,130 @@
+package dbnames
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+/*
+названия операций для тега dbop, см функцию BuildFilter
+*/
+var operationNames = map[string]Operation{
+	"isnull":    ISNULL,
+	"isnotnull": ISNOTNULL,
+	"eq":        EQUAL,
+	"ne":        NOTEQ,
+	"lt":        LESS,
+	"lte":       LESSEQ,
+	"gt":        MORE,
+	"gte":       MOREQE,
+	"in":        IN,
+	"notin":     NOTIN,
+	"like":      LIKE,
+	"notlike":   NOTLIKE,
+}
+
+/*
+функция выдает операцию по ее названию в теге dbop: eq, ne, lt, lte, gt, gte,
+in, notin, like, notlike, isnull, isnotnull
+*/
+func ParseOperation(name string) (Operation, error) {
+	if oper, ok := operationNames[strings.ToLower(strings.TrimSpace(name))]; ok {
+		return oper, nil
+	}
+	return UNDEF, fmt.Errorf("unknown operation %q", name)
+}
+
+/*
+Эта функция генерирует все условия для блока WHERE из одной структуры-фильтра.
+Оператор для каждого поля задается тегом dbop (по умолчанию eq), поэтому один и тот же
+столбец можно указать несколько раз с разными операторами:
+
+	type AuthFilter struct {
+		UserId  []UserIdType   `db:"user_id" dbop:"in"`
+		Type    *AuthType      `db:"auth_type"`
+		From    MYSQLDATETIME  `db:"create" dbop:"gte"`
+		To      MYSQLDATETIME  `db:"create" dbop:"lt"`
+		Data    string         `db:"data" dbop:"like"`
+		NoData  bool           `db:"data" dbop:"isnull"`
+	}
+
+Пустые поля пропускаются так же как в BuildConditions: nil указатель, нулевое значение
+или пустой слайс. Указатель на нулевое значение это условие. Для in и notin поле должно
+быть слайсом, для isnull и isnotnull - bool (условие добавляется если true).
+Как и остальные функции она не проставляет AND OR, см BuildWhere.
+*/
+func BuildFilter(table string, filter interface{}) ([]string, error) {
+	table = resolveTable(table, filter)
+	fillCond := make([]string, 0)
+	filterType := reflect.TypeOf(filter)
+	filterValue := reflect.ValueOf(filter)
+	for i := 0; i < filterType.NumField(); i++ {
+		fieldType := filterType.Field(i)
+		dbFieldName, find := fieldType.Tag.Lookup("db")
+		if !find {
+			continue
+		}
+		operation := EQUAL
+		if opName, ok := fieldType.Tag.Lookup("dbop"); ok {
+			oper, err := ParseOperation(opName)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", dbFieldName, err)
+			}
+			operation = oper
+		}
+		fieldValue := filterValue.Field(i)
+		if fieldValue.Kind() == reflect.Pointer {
+			if fieldValue.IsNil() {
+				continue
+			}
+			fieldValue = fieldValue.Elem()
+		} else if fieldValue.IsZero() {
+			continue
+		}
+		fullField := fullFieldName(table, dbFieldName)
+		switch operation {
+		case ISNULL, ISNOTNULL:
+			if fieldValue.Kind() != reflect.Bool {
+				return nil, fmt.Errorf("field %s: %s needs bool field", dbFieldName, fieldType.Name)
+			}
+			if fieldValue.Bool() {
+				fillCond = append(fillCond, " "+fullField+operation.ToString(""))
+			}
+		case IN, NOTIN:
+			if fieldValue.Kind() != reflect.Slice && fieldValue.Kind() != reflect.Array {
+				return nil, fmt.Errorf("field %s: %s needs slice field", dbFieldName, fieldType.Name)
+			}
+			if fieldValue.Len() == 0 {
+				continue
+			}
+			literal, err := dataLiteral(fieldValue.Type().Elem(), operation, fieldValue.Interface())
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", dbFieldName, err)
+			}
+			fillCond = append(fillCond, " "+fullField+operation.ToString(literal))
+		default:
+			literal, ok := valueLiteral(fieldValue)
+			if !ok {
+				return nil, fmt.Errorf("field %s: type %s has no sql representation", dbFieldName, fieldValue.Type())
+			}
+			fillCond = append(fillCond, " "+fullField+operation.ToString(literal))
+		}
+	}
+	return fillCond, nil
+}
+
+/*
+аналог BuildFilter который сразу соединяет условия через AND и выдает блок
+"WHERE ...", если условий нет то выдается пустая строка
+*/
+func BuildWhere(table string, filter interface{}) (string, error) {
+	fillCond, err := BuildFilter(table, filter)
+	if err != nil {
+		return "", err
+	}
+	if len(fillCond) == 0 {
+		return "", nil
+	}
+	return "WHERE" + strings.Join(fillCond, " AND"), nil
+}
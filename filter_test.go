@@ -0,0 +1,56 @@
+package dbnames
+
+import (
+	"testing"
+)
+
+type AuthFilter struct {
+	UserId []UserIdType   `db:"user_id" dbop:"in"`
+	Type   *AuthType      `db:"auth_type"`
+	From   MYSQLDATETIME  `db:"create" dbop:"gte"`
+	To     MYSQLDATETIME  `db:"create" dbop:"lt"`
+	Data   string         `db:"data" dbop:"like"`
+	NoData bool           `db:"data" dbop:"isnull"`
+	Skip   *MYSQLDATETIME `db:"create" dbop:"ne"`
+}
+
+func TestBuildFilter(t *testing.T) {
+	from := MYSQLDATETIME{}
+	from.FromString("2023-05-01 00:00:00")
+	to := MYSQLDATETIME{}
+	to.FromString("2023-06-01 00:00:00")
+	unauth := UnauthType
+	filter := AuthFilter{
+		UserId: []UserIdType{1, 2},
+		Type:   &unauth,
+		From:   from,
+		To:     to,
+		Data:   "%Denis%",
+	}
+	where, err := BuildWhere(DBAuthTable, filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "WHERE `auth`.`user_id` IN (1, 2) AND `auth`.`auth_type`=0 AND" +
+		" `auth`.`create`>='2023-05-01 00:00:00' AND `auth`.`create`<'2023-06-01 00:00:00' AND `auth`.`data` LIKE '%Denis%'"
+	if where != expected {
+		t.Errorf("bad where\n%s\n%s", where, expected)
+	}
+
+	empty, err := BuildWhere(DBAuthTable, AuthFilter{})
+	if err != nil || empty != "" {
+		t.Errorf("bad empty where %q %v", empty, err)
+	}
+
+	isNull, err := BuildFilter("", AuthFilter{NoData: true})
+	if err != nil || len(isNull) != 1 || isNull[0] != " `data` IS NULL" {
+		t.Errorf("bad is null filter %v %v", isNull, err)
+	}
+
+	type BadFilter struct {
+		UserId UserIdType `db:"user_id" dbop:"between"`
+	}
+	if _, err := BuildFilter(DBAuthTable, BadFilter{UserId: 1}); err == nil {
+		t.Errorf("unknown operation accepted")
+	}
+}
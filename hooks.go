@@ -0,0 +1,316 @@
+package dbnames
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"expvar"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+хук вызывается вокруг каждого запроса Querier из WithHooks, BeforeQuery может вернуть
+новый контекст (например со span трассировки), он же придет в AfterQuery,
+rowsAffected известен только для Exec, для запросов он -1
+*/
+type Hook interface {
+	BeforeQuery(ctx context.Context, query string, args []interface{}) context.Context
+	AfterQuery(ctx context.Context, query string, args []interface{}, rowsAffected int64, duration time.Duration, err error)
+}
+
+type hookedQuerier struct {
+	q     Querier
+	hooks []Hook
+}
+
+/*
+функция оборачивает q так, что каждый запрос проходит через hooks (в порядке перечисления
+для BeforeQuery и в обратном для AfterQuery), в транзакции WithTx надо обернуть tx отдельно
+*/
+func WithHooks(q Querier, hooks ...Hook) Querier {
+	if len(hooks) == 0 {
+		return q
+	}
+	if hooked, ok := q.(*hookedQuerier); ok {
+		return &hookedQuerier{q: hooked.q, hooks: append(append([]Hook{}, hooked.hooks...), hooks...)}
+	}
+	return &hookedQuerier{q: q, hooks: hooks}
+}
+
+func (h *hookedQuerier) before(ctx context.Context, query string, args []interface{}) context.Context {
+	for _, hook := range h.hooks {
+		ctx = hook.BeforeQuery(ctx, query, args)
+	}
+	return ctx
+}
+
+func (h *hookedQuerier) after(ctx context.Context, query string, args []interface{}, rowsAffected int64, start time.Time, err error) {
+	duration := time.Since(start)
+	for i := len(h.hooks) - 1; i >= 0; i-- {
+		h.hooks[i].AfterQuery(ctx, query, args, rowsAffected, duration, err)
+	}
+}
+
+func (h *hookedQuerier) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx = h.before(ctx, query, args)
+	start := time.Now()
+	result, err := h.q.ExecContext(ctx, query, args...)
+	rowsAffected := int64(-1)
+	if err == nil {
+		if affected, err := result.RowsAffected(); err == nil {
+			rowsAffected = affected
+		}
+	}
+	h.after(ctx, query, args, rowsAffected, start, err)
+	return result, err
+}
+
+func (h *hookedQuerier) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	ctx = h.before(ctx, query, args)
+	start := time.Now()
+	rows, err := h.q.QueryContext(ctx, query, args...)
+	h.after(ctx, query, args, -1, start, err)
+	return rows, err
+}
+
+func (h *hookedQuerier) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	ctx = h.before(ctx, query, args)
+	start := time.Now()
+	row := h.q.QueryRowContext(ctx, query, args...)
+	h.after(ctx, query, args, -1, start, row.Err())
+	return row
+}
+
+// эта часть файла содержит готовые хуки
+
+// SlogHook пишет каждый запрос в slog: успешные с уровнем Level, ошибки с уровнем Error
+type SlogHook struct {
+	Logger *slog.Logger
+	Level  slog.Level
+	// не писать значения параметров (например если там пароли)
+	HideArgs bool
+}
+
+func NewSlogHook(logger *slog.Logger) *SlogHook {
+	return &SlogHook{Logger: logger, Level: slog.LevelDebug}
+}
+
+func (h *SlogHook) BeforeQuery(ctx context.Context, query string, args []interface{}) context.Context {
+	return ctx
+}
+
+func (h *SlogHook) AfterQuery(ctx context.Context, query string, args []interface{}, rowsAffected int64, duration time.Duration, err error) {
+	level := h.Level
+	if err != nil {
+		level = slog.LevelError
+	}
+	if !h.Logger.Enabled(ctx, level) {
+		return
+	}
+	h.Logger.LogAttrs(ctx, level, "query", queryAttrs(query, args, h.HideArgs, rowsAffected, duration, err)...)
+}
+
+// SlowQueryHook пишет с уровнем Warn запросы которые выполнялись не меньше Threshold
+type SlowQueryHook struct {
+	Logger    *slog.Logger
+	Threshold time.Duration
+	HideArgs  bool
+}
+
+func NewSlowQueryHook(logger *slog.Logger, threshold time.Duration) *SlowQueryHook {
+	return &SlowQueryHook{Logger: logger, Threshold: threshold}
+}
+
+func (h *SlowQueryHook) BeforeQuery(ctx context.Context, query string, args []interface{}) context.Context {
+	return ctx
+}
+
+func (h *SlowQueryHook) AfterQuery(ctx context.Context, query string, args []interface{}, rowsAffected int64, duration time.Duration, err error) {
+	if duration < h.Threshold {
+		return
+	}
+	h.Logger.LogAttrs(ctx, slog.LevelWarn, "slow query", queryAttrs(query, args, h.HideArgs, rowsAffected, duration, err)...)
+}
+
+func queryAttrs(query string, args []interface{}, hideArgs bool, rowsAffected int64, duration time.Duration, err error) []slog.Attr {
+	attrs := []slog.Attr{slog.String("sql", query), slog.Duration("duration", duration)}
+	if !hideArgs && len(args) > 0 {
+		attrs = append(attrs, slog.Any("args", args))
+	}
+	if rowsAffected >= 0 {
+		attrs = append(attrs, slog.Int64("rows", rowsAffected))
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	return attrs
+}
+
+// верхние границы корзин гистограммы задержек Metrics, последняя корзина без границы
+var MetricsBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+}
+
+// StatementStats статистика одного нормализованного выражения (см NormalizeStatement)
+type StatementStats struct {
+	Count   uint64  `json:"count"`
+	Errors  uint64  `json:"errors"`
+	TotalMs float64 `json:"total_ms"`
+	MaxMs   float64 `json:"max_ms"`
+	// число запросов по корзинам MetricsBuckets, последний элемент для более долгих
+	Buckets []uint64 `json:"buckets"`
+}
+
+/*
+хук собирает счетчики и гистограммы задержек по нормализованным выражениям,
+реализует expvar.Var, публикуется через Publish и отдается в /debug/vars
+*/
+type Metrics struct {
+	mutex sync.Mutex
+	stats map[string]*StatementStats
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{stats: make(map[string]*StatementStats)}
+}
+
+func (m *Metrics) BeforeQuery(ctx context.Context, query string, args []interface{}) context.Context {
+	return ctx
+}
+
+func (m *Metrics) AfterQuery(ctx context.Context, query string, args []interface{}, rowsAffected int64, duration time.Duration, err error) {
+	statement := NormalizeStatement(query)
+	bucket := sort.Search(len(MetricsBuckets), func(i int) bool { return duration <= MetricsBuckets[i] })
+	ms := float64(duration) / float64(time.Millisecond)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	stats, ok := m.stats[statement]
+	if !ok {
+		stats = &StatementStats{Buckets: make([]uint64, len(MetricsBuckets)+1)}
+		m.stats[statement] = stats
+	}
+	stats.Count++
+	if err != nil {
+		stats.Errors++
+	}
+	stats.TotalMs += ms
+	if ms > stats.MaxMs {
+		stats.MaxMs = ms
+	}
+	stats.Buckets[bucket]++
+}
+
+var _ expvar.Var = (*Metrics)(nil)
+
+// Publish публикует статистику в expvar под именем name (повторная публикация имени паникует)
+func (m *Metrics) Publish(name string) {
+	expvar.Publish(name, m)
+}
+
+// Snapshot копия текущей статистики по выражениям
+func (m *Metrics) Snapshot() map[string]StatementStats {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	out := make(map[string]StatementStats, len(m.stats))
+	for statement, stats := range m.stats {
+		copied := *stats
+		copied.Buckets = append([]uint64(nil), stats.Buckets...)
+		out[statement] = copied
+	}
+	return out
+}
+
+// Reset очищает статистику
+func (m *Metrics) Reset() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.stats = make(map[string]*StatementStats)
+}
+
+// String отдает статистику в JSON для expvar
+func (m *Metrics) String() string {
+	data, err := json.Marshal(m.Snapshot())
+	if err != nil {
+		return "{}"
+	}
+	return string(data)
+}
+
+var placeholderList = regexp.MustCompile(`\(\s*\?(?:\s*,\s*\?)+\s*\)`)
+
+/*
+функция приводит запрос к виду для группировки статистики: строковые и числовые литералы
+заменяются на ?, списки (?, ?, ?) сворачиваются в (?+), пробелы схлопываются
+*/
+func NormalizeStatement(query string) string {
+	var out strings.Builder
+	out.Grow(len(query))
+	space := false
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			space = true
+			continue
+		case c == '\'':
+			// строка до закрывающей кавычки, '' и \' внутри пропускаются
+			for i++; i < len(query); i++ {
+				if query[i] == '\\' {
+					i++
+				} else if query[i] == '\'' {
+					if i+1 < len(query) && query[i+1] == '\'' {
+						i++
+					} else {
+						break
+					}
+				}
+			}
+			c = '?'
+		case c == '`' || c == '"':
+			// имена в кавычках копируются как есть
+			stop := len(query)
+			if end := strings.IndexByte(query[i+1:], c); end >= 0 {
+				stop = i + end + 2
+			}
+			if space && out.Len() > 0 {
+				out.WriteByte(' ')
+			}
+			space = false
+			out.WriteString(query[i:stop])
+			i = stop - 1
+			continue
+		case c >= '0' && c <= '9' && !identifierTail(query, i):
+			for i+1 < len(query) && (query[i+1] >= '0' && query[i+1] <= '9' || query[i+1] == '.') {
+				i++
+			}
+			c = '?'
+		}
+		if space && out.Len() > 0 {
+			out.WriteByte(' ')
+		}
+		space = false
+		out.WriteByte(c)
+	}
+	return placeholderList.ReplaceAllString(out.String(), "(?+)")
+}
+
+// цифра продолжает имя (t1, $2) а не начинает число
+func identifierTail(query string, i int) bool {
+	if i == 0 {
+		return false
+	}
+	p := query[i-1]
+	return p == '_' || p == '$' || p >= 'a' && p <= 'z' || p >= 'A' && p <= 'Z' || p >= '0' && p <= '9'
+}
@@ -0,0 +1,121 @@
+package dbnames
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/denisbdn/dbnames/dbnamestest"
+)
+
+type recordHook struct {
+	name  string
+	calls *[]string
+}
+
+type hookKey struct{}
+
+func (h recordHook) BeforeQuery(ctx context.Context, query string, args []interface{}) context.Context {
+	*h.calls = append(*h.calls, "before "+h.name)
+	return context.WithValue(ctx, hookKey{}, h.name)
+}
+
+func (h recordHook) AfterQuery(ctx context.Context, query string, args []interface{}, rowsAffected int64, duration time.Duration, err error) {
+	*h.calls = append(*h.calls, "after "+h.name+" "+ctx.Value(hookKey{}).(string))
+}
+
+func TestWithHooks(t *testing.T) {
+	db, mock, err := dbnamestest.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	calls := make([]string, 0)
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	metrics := NewMetrics()
+	q := WithHooks(WithHooks(db, recordHook{"a", &calls}), recordHook{"b", &calls}, NewSlogHook(logger), metrics)
+
+	mock.ExpectExec("UPDATE `auth` SET `auth_type` = 1 WHERE `user_id` = ?").WithArgs(184216).WillReturnResult(0, 1)
+	mock.ExpectExec("UPDATE `auth` SET `auth_type` = 2 WHERE `user_id` = ?").WithArgs(184217).WillReturnError(errors.New("failed"))
+	mock.ExpectQuery("SELECT `user_id` FROM `auth` WHERE `user_id` IN (?, ?)").WithArgs(1, 2).
+		WillReturnRows(dbnamestest.NewRows("user_id").AddRow(1))
+	if _, err := q.ExecContext(ctx, "UPDATE `auth` SET `auth_type` = 1 WHERE `user_id` = ?", 184216); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.ExecContext(ctx, "UPDATE `auth` SET `auth_type` = 2 WHERE `user_id` = ?", 184217); err == nil {
+		t.Fatal("error lost")
+	}
+	var userID int
+	if err := q.QueryRowContext(ctx, "SELECT `user_id` FROM `auth` WHERE `user_id` IN (?, ?)", 1, 2).Scan(&userID); err != nil || userID != 1 {
+		t.Fatal(userID, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(calls[:4], ",") != "before a,before b,after b b,after a b" {
+		t.Errorf("bad hook order %v", calls)
+	}
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("bad log %s", logs.String())
+	}
+	entry := make(map[string]interface{})
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["level"] != "DEBUG" || entry["rows"] != 1.0 || entry["sql"] != "UPDATE `auth` SET `auth_type` = 1 WHERE `user_id` = ?" {
+		t.Errorf("bad log entry %v", entry)
+	}
+	if !strings.Contains(lines[1], `"level":"ERROR"`) || !strings.Contains(lines[1], `"error":"failed"`) {
+		t.Errorf("bad error entry %s", lines[1])
+	}
+
+	stats := metrics.Snapshot()
+	update := stats["UPDATE `auth` SET `auth_type` = ? WHERE `user_id` = ?"]
+	if len(stats) != 2 || update.Count != 2 || update.Errors != 1 || len(update.Buckets) != len(MetricsBuckets)+1 {
+		t.Errorf("bad stats %+v", stats)
+	}
+	if stats["SELECT `user_id` FROM `auth` WHERE `user_id` IN (?+)"].Count != 1 {
+		t.Errorf("bad stats %+v", stats)
+	}
+	metrics.Publish("dbnames_test")
+	if !strings.Contains(expvar.Get("dbnames_test").String(), `"errors":1`) {
+		t.Errorf("bad expvar %s", expvar.Get("dbnames_test"))
+	}
+}
+
+func TestSlowQueryHook(t *testing.T) {
+	var logs bytes.Buffer
+	hook := NewSlowQueryHook(slog.New(slog.NewTextHandler(&logs, nil)), 100*time.Millisecond)
+	hook.AfterQuery(context.Background(), "SELECT 1", nil, -1, 10*time.Millisecond, nil)
+	if logs.Len() != 0 {
+		t.Errorf("fast query logged %s", logs.String())
+	}
+	hook.AfterQuery(context.Background(), "SELECT 2", nil, -1, 200*time.Millisecond, nil)
+	if !strings.Contains(logs.String(), "slow query") || !strings.Contains(logs.String(), "SELECT 2") {
+		t.Errorf("slow query not logged %s", logs.String())
+	}
+}
+
+func TestNormalizeStatement(t *testing.T) {
+	cases := map[string]string{
+		"SELECT * FROM `t1` WHERE `a` = 'it''s' AND `b`=10":            "SELECT * FROM `t1` WHERE `a` = ? AND `b`=?",
+		"SELECT  *\n FROM t2 WHERE a IN (1, 2,3) AND b = 'x\\'y'":      "SELECT * FROM t2 WHERE a IN (?+) AND b = ?",
+		`SELECT "col 1" FROM "t" WHERE "x" = $1 AND y = 1.5`:           `SELECT "col 1" FROM "t" WHERE "x" = $1 AND y = ?`,
+		"INSERT INTO `auth` (`user_id`, `data`) VALUES (?, ?), (?, ?)": "INSERT INTO `auth` (`user_id`, `data`) VALUES (?+), (?+)",
+	}
+	for query, expected := range cases {
+		if normalized := NormalizeStatement(query); normalized != expected {
+			t.Errorf("bad normalize %q -> %q", query, normalized)
+		}
+	}
+}
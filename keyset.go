@@ -0,0 +1,213 @@
+package dbnames
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+/*
+Keyset помогает листать таблицу без OFFSET: следующая страница выбирается условием
+по ключевым столбцам последней полученной строки
+
+	ks, _ := NewKeyset(DBAuthTable, DBAuth{}, "-create", "user_id")
+	where, _ := ks.Where(last)   //  (`auth`.`create` < '...' OR (`auth`.`create` = '...' AND `auth`.`user_id` > 184216))
+	order := ks.OrderBy()        // ORDER BY `auth`.`create` DESC, `auth`.`user_id` ASC
+	cursor, _ := ks.Cursor(last) // непрозрачная строка для api, см Decode
+
+ключи задаются как в BuildOrderBy, последний ключ должен быть уникальным
+(обычно первичный ключ), иначе строки с одинаковыми ключами потеряются
+*/
+type Keyset struct {
+	table  string
+	model  reflect.Type
+	keys   []sortField
+	fields []int
+}
+
+func NewKeyset(table string, model interface{}, keys ...string) (*Keyset, error) {
+	table = resolveTable(table, model)
+	sortFields, err := parseSortFields(model, keys)
+	if err != nil {
+		return nil, err
+	}
+	if len(sortFields) == 0 {
+		return nil, fmt.Errorf("no keys")
+	}
+	modelType := reflect.TypeOf(model)
+	fields := make([]int, 0, len(sortFields))
+	for _, key := range sortFields {
+		for i := 0; i < modelType.NumField(); i++ {
+			if dbFieldName, find := modelType.Field(i).Tag.Lookup("db"); find && dbFieldName == key.name {
+				fields = append(fields, i)
+				break
+			}
+		}
+	}
+	return &Keyset{table: table, model: modelType, keys: sortFields, fields: fields}, nil
+}
+
+/*
+блок ORDER BY который должен быть в запросе вместе с условием Where
+*/
+func (k *Keyset) OrderBy() string {
+	orders := make([]string, 0, len(k.keys))
+	for _, key := range k.keys {
+		direction := "ASC"
+		if key.desc {
+			direction = "DESC"
+		}
+		orders = append(orders, fullFieldName(k.table, key.name)+" "+direction)
+	}
+	return "ORDER BY " + strings.Join(orders, ", ")
+}
+
+/*
+условие для строк после last (структура или указатель на нее того же типа что и model),
+начинается с пробела как и результаты BuildConditions, поэтому их можно объединять.
+Если все ключи в одном направлении то сравнивается кортеж (a, b) > (x, y),
+иначе условие раскрывается: a > x OR (a = x AND b < y)
+*/
+func (k *Keyset) Where(last interface{}) (string, error) {
+	literals, err := k.literals(last)
+	if err != nil {
+		return "", err
+	}
+	sameDirection := true
+	for _, key := range k.keys {
+		if key.desc != k.keys[0].desc {
+			sameDirection = false
+		}
+	}
+	if sameDirection {
+		oper := MORE
+		if k.keys[0].desc {
+			oper = LESS
+		}
+		if len(k.keys) == 1 {
+			return " " + fullFieldName(k.table, k.keys[0].name) + oper.ToString(literals[0]), nil
+		}
+		names := make([]string, 0, len(k.keys))
+		for _, key := range k.keys {
+			names = append(names, fullFieldName(k.table, key.name))
+		}
+		return fmt.Sprintf(" (%s)%s", strings.Join(names, ", "), oper.ToString("("+strings.Join(literals, ", ")+")")), nil
+	}
+	ors := make([]string, 0, len(k.keys))
+	for i, key := range k.keys {
+		ands := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			ands = append(ands, fullFieldName(k.table, k.keys[j].name)+EQUAL.ToString(literals[j]))
+		}
+		oper := MORE
+		if key.desc {
+			oper = LESS
+		}
+		ands = append(ands, fullFieldName(k.table, key.name)+oper.ToString(literals[i]))
+		if len(ands) == 1 {
+			ors = append(ors, ands[0])
+		} else {
+			ors = append(ors, "("+strings.Join(ands, " AND ")+")")
+		}
+	}
+	return " (" + strings.Join(ors, " OR ") + ")", nil
+}
+
+/*
+курсор - base64 от json с ключами строки last, его можно отдать клиенту
+и получить обратно для следующей страницы
+*/
+func (k *Keyset) Cursor(last interface{}) (string, error) {
+	lastValue, err := k.value(last)
+	if err != nil {
+		return "", err
+	}
+	values := make(map[string]json.RawMessage, len(k.keys))
+	for i, key := range k.keys {
+		js, err := json.Marshal(lastValue.Field(k.fields[i]).Interface())
+		if err != nil {
+			return "", fmt.Errorf("key %s: %w", key.name, err)
+		}
+		values[key.name] = js
+	}
+	js, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(js), nil
+}
+
+/*
+заполняет ключевые поля структуры по указателю last из курсора, остальные поля не трогает
+*/
+func (k *Keyset) Decode(cursor string, last interface{}) error {
+	ptr := reflect.ValueOf(last)
+	if ptr.Kind() != reflect.Pointer || ptr.IsNil() || ptr.Elem().Type() != k.model {
+		return fmt.Errorf("need pointer to %s", k.model)
+	}
+	js, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return fmt.Errorf("bad cursor: %w", err)
+	}
+	values := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(js, &values); err != nil {
+		return fmt.Errorf("bad cursor: %w", err)
+	}
+	for i, key := range k.keys {
+		js, ok := values[key.name]
+		if !ok {
+			return fmt.Errorf("bad cursor: no key %s", key.name)
+		}
+		if err := json.Unmarshal(js, ptr.Elem().Field(k.fields[i]).Addr().Interface()); err != nil {
+			return fmt.Errorf("bad cursor: key %s: %w", key.name, err)
+		}
+	}
+	return nil
+}
+
+/*
+условие для строк после курсора, см Decode и Where
+*/
+func (k *Keyset) WhereCursor(cursor string) (string, error) {
+	last := reflect.New(k.model)
+	if err := k.Decode(cursor, last.Interface()); err != nil {
+		return "", err
+	}
+	return k.Where(last.Interface())
+}
+
+func (k *Keyset) value(last interface{}) (reflect.Value, error) {
+	lastValue := reflect.ValueOf(last)
+	if lastValue.Kind() == reflect.Pointer && !lastValue.IsNil() {
+		lastValue = lastValue.Elem()
+	}
+	if !lastValue.IsValid() || lastValue.Type() != k.model {
+		return reflect.Value{}, fmt.Errorf("need %s", k.model)
+	}
+	return lastValue, nil
+}
+
+func (k *Keyset) literals(last interface{}) ([]string, error) {
+	lastValue, err := k.value(last)
+	if err != nil {
+		return nil, err
+	}
+	literals := make([]string, 0, len(k.keys))
+	for i, key := range k.keys {
+		fieldValue := lastValue.Field(k.fields[i])
+		if fieldValue.Kind() == reflect.Pointer {
+			if fieldValue.IsNil() {
+				return nil, fmt.Errorf("key %s is nil", key.name)
+			}
+			fieldValue = fieldValue.Elem()
+		}
+		literal, ok := valueLiteral(fieldValue)
+		if !ok {
+			return nil, fmt.Errorf("key %s: type %s has no sql representation", key.name, fieldValue.Type())
+		}
+		literals = append(literals, literal)
+	}
+	return literals, nil
+}
@@ -0,0 +1,55 @@
+package dbnames
+
+import (
+	"testing"
+)
+
+func TestKeyset(t *testing.T) {
+	last := DBAuth{UserId: 184216, Type: PhoneType, Data: "{}"}
+	last.Create.FromString("2023-05-12 21:41:23")
+
+	ks, err := NewKeyset(DBAuthTable, DBAuth{}, "-create", "user_id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order := ks.OrderBy(); order != "ORDER BY `auth`.`create` DESC, `auth`.`user_id` ASC" {
+		t.Errorf("bad order %s", order)
+	}
+	where, err := ks.Where(last)
+	if err != nil {
+		t.Error(err)
+	} else if where != " (`auth`.`create`<'2023-05-12 21:41:23' OR (`auth`.`create`='2023-05-12 21:41:23' AND `auth`.`user_id`>184216))" {
+		t.Errorf("bad mixed where %s", where)
+	}
+
+	same, err := NewKeyset(DBAuthTable, DBAuth{}, "create,user_id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if where, err := same.Where(&last); err != nil || where != " (`auth`.`create`, `auth`.`user_id`)>('2023-05-12 21:41:23', 184216)" {
+		t.Errorf("bad tuple where %s %v", where, err)
+	}
+
+	cursor, err := ks.Cursor(last)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log("cursor", cursor)
+	decoded := DBAuth{}
+	if err := ks.Decode(cursor, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.UserId != last.UserId || decoded.Create.Unix() != last.Create.Unix() || decoded.Data != "" {
+		t.Errorf("bad decoded cursor %v", decoded)
+	}
+	if whereCursor, err := ks.WhereCursor(cursor); err != nil || whereCursor != where {
+		t.Errorf("bad cursor where %s %v", whereCursor, err)
+	}
+	if err := ks.Decode("not a cursor", &decoded); err == nil {
+		t.Errorf("bad cursor accepted")
+	}
+
+	if _, err := NewKeyset(DBAuthTable, DBAuth{}, "first_begin"); err == nil {
+		t.Errorf("unknown key accepted")
+	}
+}
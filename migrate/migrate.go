@@ -0,0 +1,521 @@
+/*
+пакет выполняет версионные миграции схемы базы: файлы NNN_name.up.sql и
+NNN_name.down.sql из каталога или embed.FS, применённые версии хранятся
+в таблице schema_migrations
+
+	//go:embed migrations/*.sql
+	var migrations embed.FS
+
+	m, err := migrate.New(db, dbnames.DIALECTMYSQL, migrations, "migrations")
+	err = m.Up(ctx)
+*/
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/denisbdn/dbnames"
+)
+
+type Migration struct {
+	Version  uint64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string // sha256 от Up и Down в hex
+}
+
+/*
+состояние миграции для Status, AppliedAt нулевое если миграция не применена,
+Modified означает что файл изменили после применения
+*/
+type MigrationStatus struct {
+	Version   uint64
+	Name      string
+	Applied   bool
+	AppliedAt dbnames.MYSQLDATETIME
+	Modified  bool
+	Missing   bool // применена, но файла нет
+}
+
+/*
+строка таблицы schema_migrations, по ней же строится CREATE TABLE
+*/
+type appliedMigration struct {
+	Version   uint64                `db:"version" dbddl:"pk"`
+	Name      string                `db:"name"`
+	Checksum  string                `db:"checksum" dbtype:"char(64)"`
+	AppliedAt dbnames.MYSQLDATETIME `db:"applied_at"`
+}
+
+var fileName = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+/*
+функция читает миграции из каталога dir в fsys (os.DirFS или embed.FS),
+другие файлы пропускаются, у каждой версии должен быть up файл,
+версия 0 запрещена: Goto(0) означает откат всех миграций
+*/
+func Load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[uint64]*Migration)
+	hasUp := make(map[uint64]bool)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		version, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		if version == 0 {
+			return nil, fmt.Errorf("%s: version 0 is reserved", entry.Name())
+		}
+		body, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("version %d has two names %s and %s", version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.Up = string(body)
+			hasUp[version] = true
+		} else {
+			migration.Down = string(body)
+		}
+	}
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if !hasUp[migration.Version] {
+			return nil, fmt.Errorf("version %d has no up file", migration.Version)
+		}
+		migration.Checksum = checksum(migration.Up, migration.Down)
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+/*
+контрольная сумма миграции: правка и up и down файла после применения видна в Status
+*/
+func checksum(up string, down string) string {
+	h := sha256.New()
+	h.Write([]byte(up))
+	h.Write([]byte{0})
+	h.Write([]byte(down))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+type Migrator struct {
+	db          *sql.DB
+	dialect     dbnames.Dialect
+	migrations  []Migration
+	Table       string        // таблица учета, по умолчанию schema_migrations
+	LockName    string        // имя блокировки, по умолчанию совпадает с Table
+	LockTimeout time.Duration // сколько ждать блокировку (только MySQL)
+}
+
+func New(db *sql.DB, dialect dbnames.Dialect, fsys fs.FS, dir string) (*Migrator, error) {
+	migrations, err := Load(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{
+		db:          db,
+		dialect:     dialect,
+		migrations:  migrations,
+		Table:       "schema_migrations",
+		LockTimeout: time.Minute,
+	}, nil
+}
+
+func (m *Migrator) Migrations() []Migration {
+	return m.migrations
+}
+
+/*
+применяет все еще не примененные миграции по возрастанию версий
+*/
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.run(ctx, func(conn *sql.Conn, applied map[uint64]appliedMigration) error {
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			if err := m.apply(ctx, conn, migration, true); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+/*
+откатывает n последних примененных миграций
+*/
+func (m *Migrator) Down(ctx context.Context, n int) error {
+	return m.run(ctx, func(conn *sql.Conn, applied map[uint64]appliedMigration) error {
+		for i := len(m.migrations) - 1; i >= 0 && n > 0; i-- {
+			if _, ok := applied[m.migrations[i].Version]; !ok {
+				continue
+			}
+			if err := m.apply(ctx, conn, m.migrations[i], false); err != nil {
+				return err
+			}
+			n--
+		}
+		return nil
+	})
+}
+
+/*
+приводит базу к версии version: применяет миграции до нее включительно
+и откатывает все что выше, 0 откатывает все
+*/
+func (m *Migrator) Goto(ctx context.Context, version uint64) error {
+	if version != 0 {
+		found := false
+		for _, migration := range m.migrations {
+			found = found || migration.Version == version
+		}
+		if !found {
+			return fmt.Errorf("unknown version %d", version)
+		}
+	}
+	return m.run(ctx, func(conn *sql.Conn, applied map[uint64]appliedMigration) error {
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			if _, ok := applied[m.migrations[i].Version]; ok && m.migrations[i].Version > version {
+				if err := m.apply(ctx, conn, m.migrations[i], false); err != nil {
+					return err
+				}
+			}
+		}
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; !ok && migration.Version <= version {
+				if err := m.apply(ctx, conn, migration, true); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+/*
+выдает состояние всех миграций из файлов и из таблицы учета
+*/
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err := m.createTable(ctx, conn); err != nil {
+		return nil, err
+	}
+	applied, err := m.applied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	status := make([]MigrationStatus, 0, len(m.migrations))
+	known := make(map[uint64]bool)
+	for _, migration := range m.migrations {
+		known[migration.Version] = true
+		item := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if row, ok := applied[migration.Version]; ok {
+			item.Applied = true
+			item.AppliedAt = row.AppliedAt
+			item.Modified = row.Checksum != migration.Checksum
+		}
+		status = append(status, item)
+	}
+	for version, row := range applied {
+		if !known[version] {
+			status = append(status, MigrationStatus{Version: version, Name: row.Name, Applied: true, AppliedAt: row.AppliedAt, Missing: true})
+		}
+	}
+	sort.Slice(status, func(i, j int) bool { return status[i].Version < status[j].Version })
+	return status, nil
+}
+
+/*
+общая часть Up, Down и Goto: берет соединение и блокировку, создает таблицу учета,
+проверяет контрольные суммы примененных миграций
+*/
+func (m *Migrator) run(ctx context.Context, fn func(conn *sql.Conn, applied map[uint64]appliedMigration) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := m.lock(ctx, conn); err != nil {
+		return err
+	}
+	defer m.unlock(conn)
+	if err := m.createTable(ctx, conn); err != nil {
+		return err
+	}
+	applied, err := m.applied(ctx, conn)
+	if err != nil {
+		return err
+	}
+	known := make(map[uint64]bool)
+	for _, migration := range m.migrations {
+		known[migration.Version] = true
+		if row, ok := applied[migration.Version]; ok && row.Checksum != migration.Checksum {
+			return fmt.Errorf("migration %d_%s was modified after it was applied", migration.Version, migration.Name)
+		}
+	}
+	for version, row := range applied {
+		if !known[version] {
+			return fmt.Errorf("migration %d_%s is applied but its file is missing", version, row.Name)
+		}
+	}
+	return fn(conn, applied)
+}
+
+func (m *Migrator) lockName() string {
+	if len(m.LockName) > 0 {
+		return m.LockName
+	}
+	return m.Table
+}
+
+/*
+блокировка чтобы мигрировал только один экземпляр сервиса, держится на соединении conn
+*/
+func (m *Migrator) lock(ctx context.Context, conn *sql.Conn) error {
+	switch m.dialect {
+	case dbnames.DIALECTMYSQL:
+		var got sql.NullInt64
+		if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", m.lockName(), int(m.LockTimeout.Seconds())).Scan(&got); err != nil {
+			return err
+		}
+		if !got.Valid || got.Int64 != 1 {
+			return fmt.Errorf("can't get lock %s", m.lockName())
+		}
+	case dbnames.DIALECTPOSTGRES:
+		if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", m.lockKey()); err != nil {
+			return err
+		}
+	}
+	// в SQLite писать может только одно соединение, отдельная блокировка не нужна
+	return nil
+}
+
+func (m *Migrator) unlock(conn *sql.Conn) {
+	ctx := context.Background()
+	switch m.dialect {
+	case dbnames.DIALECTMYSQL:
+		conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", m.lockName())
+	case dbnames.DIALECTPOSTGRES:
+		conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", m.lockKey())
+	}
+}
+
+func (m *Migrator) lockKey() int64 {
+	h := fnv.New64a()
+	h.Write([]byte(m.lockName()))
+	return int64(h.Sum64())
+}
+
+func (m *Migrator) createTable(ctx context.Context, conn *sql.Conn) error {
+	table, err := dbnames.TableFromStruct(m.Table, appliedMigration{}, m.dialect)
+	if err != nil {
+		return err
+	}
+	table.IfNotExists = true
+	for _, statement := range SplitStatements(table.CreateSQL(m.dialect), m.dialect) {
+		if _, err := conn.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn) (map[uint64]appliedMigration, error) {
+	fields := dbnames.BuildFields("", appliedMigration{})
+	query := fmt.Sprintf("SELECT %s FROM %s", strings.Join(fields, ", "), m.dialect.Quote(m.Table))
+	if m.dialect != dbnames.DIALECTMYSQL {
+		query = strings.ReplaceAll(query, "`", `"`)
+	}
+	rows, err := conn.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res, err := dbnames.New(rows)
+	if err != nil {
+		return nil, err
+	}
+	applied := make(map[uint64]appliedMigration)
+	for res.Next() {
+		if err := res.Scan(); err != nil {
+			return nil, err
+		}
+		row := appliedMigration{}
+		dbnames.FillByDBResult(res, &row)
+		applied[row.Version] = row
+	}
+	return applied, rows.Err()
+}
+
+/*
+выполняет up или down скрипт миграции и обновляет таблицу учета в одной транзакции
+(учтите что в MySQL DDL выражения завершают транзакцию неявно)
+*/
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, migration Migration, up bool) error {
+	script := migration.Up
+	if !up {
+		script = migration.Down
+		if len(strings.TrimSpace(script)) == 0 {
+			return fmt.Errorf("migration %d_%s has no down file", migration.Version, migration.Name)
+		}
+	}
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	for _, statement := range SplitStatements(script, m.dialect) {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+	}
+	var bookkeeping string
+	var args []interface{}
+	if up {
+		fields := dbnames.BuildFields("", appliedMigration{}, "version", "name", "checksum", "applied_at")
+		bookkeeping = fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s, %s, %s, CURRENT_TIMESTAMP)",
+			m.dialect.Quote(m.Table), strings.Join(fields, ", "),
+			m.dialect.Placeholder(1), m.dialect.Placeholder(2), m.dialect.Placeholder(3))
+		args = []interface{}{migration.Version, migration.Name, migration.Checksum}
+	} else {
+		cond := dbnames.BuildConditions("", appliedMigration{Version: migration.Version}, dbnames.EQUAL)
+		bookkeeping = fmt.Sprintf("DELETE FROM %s WHERE%s", m.dialect.Quote(m.Table), strings.Join(cond, " AND"))
+	}
+	if m.dialect != dbnames.DIALECTMYSQL {
+		bookkeeping = strings.ReplaceAll(bookkeeping, "`", `"`)
+	}
+	if _, err := tx.ExecContext(ctx, bookkeeping, args...); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+/*
+функция делит скрипт на отдельные выражения по точке с запятой, точки с запятой
+в строках, именах и комментариях не учитываются, пустые выражения пропускаются.
+Различия диалектов: комментарий от # до конца строки и \ внутри строк есть только
+в MySQL (в postgres # это оператор, а строки standard_conforming_strings), тела
+$$...$$ и $tag$...$tag$ (функции, триггеры, DO) есть только в postgres
+*/
+func SplitStatements(script string, dialect dbnames.Dialect) []string {
+	statements := make([]string, 0)
+	var sb strings.Builder
+	flush := func() {
+		if statement := strings.TrimSpace(sb.String()); len(statement) > 0 {
+			statements = append(statements, statement)
+		}
+		sb.Reset()
+	}
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		switch {
+		case c == ';':
+			flush()
+		case c == '\'' || c == '"' || c == '`':
+			// в postgres \ экранирует только в строках E'...'
+			escapes := c == '\'' && (dialect == dbnames.DIALECTMYSQL ||
+				i > 0 && (script[i-1] == 'E' || script[i-1] == 'e') && (i == 1 || !identByte(script[i-2])))
+			j := i + 1
+			for ; j < len(script); j++ {
+				if script[j] == '\\' && escapes {
+					j++
+					continue
+				}
+				if script[j] == c {
+					break
+				}
+			}
+			if j >= len(script) {
+				j = len(script) - 1
+			}
+			sb.WriteString(script[i : j+1])
+			i = j
+		case c == '$' && dialect == dbnames.DIALECTPOSTGRES && (i == 0 || !identByte(script[i-1])) && dollarTag(script[i:]) != "":
+			tag := dollarTag(script[i:])
+			end := strings.Index(script[i+len(tag):], tag)
+			if end < 0 {
+				sb.WriteString(script[i:])
+				i = len(script)
+			} else {
+				sb.WriteString(script[i : i+len(tag)+end+len(tag)])
+				i += len(tag) + end + len(tag) - 1
+			}
+		case c == '-' && strings.HasPrefix(script[i:], "--"), c == '#' && dialect == dbnames.DIALECTMYSQL:
+			for i < len(script) && script[i] != '\n' {
+				i++
+			}
+			sb.WriteByte('\n')
+		case c == '/' && strings.HasPrefix(script[i:], "/*"):
+			end := strings.Index(script[i+2:], "*/")
+			if end < 0 {
+				i = len(script)
+			} else {
+				sb.WriteString(script[i : i+end+4])
+				i += end + 3
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	flush()
+	return statements
+}
+
+/*
+метка начала тела в долларах в начале str: $$ или $tag$, пустая строка если это не метка
+(например параметр $1)
+*/
+func dollarTag(str string) string {
+	for k := 1; k < len(str); k++ {
+		switch c := str[k]; {
+		case c == '$':
+			return str[:k+1]
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || k > 1 && c >= '0' && c <= '9':
+		default:
+			return ""
+		}
+	}
+	return ""
+}
+
+func identByte(c byte) bool {
+	return c == '_' || c == '$' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
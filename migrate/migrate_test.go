@@ -0,0 +1,166 @@
+package migrate
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/denisbdn/dbnames"
+	"github.com/denisbdn/dbnames/dbnamestest"
+)
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/001_auth.up.sql":    {Data: []byte("CREATE TABLE `auth` (`user_id` int unsigned NOT NULL);")},
+		"migrations/001_auth.down.sql":  {Data: []byte("DROP TABLE `auth`;")},
+		"migrations/002_index.up.sql":   {Data: []byte("ALTER TABLE `auth` ADD KEY `idx_user_id` (`user_id`);")},
+		"migrations/README.md":          {Data: []byte("not a migration")},
+		"migrations/010_data.up.sql":    {Data: []byte("INSERT INTO `auth` VALUES (1);")},
+		"migrations/010_data.down.sql":  {Data: []byte("DELETE FROM `auth`;")},
+		"migrations/other/003_x.up.sql": {Data: []byte("SELECT 1;")},
+		"migrations/002_index.down.sql": {Data: []byte("ALTER TABLE `auth` DROP INDEX `idx_user_id`;")},
+	}
+	migrations, err := Load(fsys, "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) != 3 || migrations[0].Version != 1 || migrations[1].Version != 2 || migrations[2].Version != 10 {
+		t.Fatalf("bad migrations %v", migrations)
+	}
+	if migrations[0].Name != "auth" || migrations[0].Down != "DROP TABLE `auth`;" || len(migrations[0].Checksum) != 64 {
+		t.Errorf("bad migration %+v", migrations[0])
+	}
+
+	// правка down файла меняет контрольную сумму
+	fsys["migrations/001_auth.down.sql"] = &fstest.MapFile{Data: []byte("DROP TABLE IF EXISTS `auth`;")}
+	if changed, err := Load(fsys, "migrations"); err != nil || changed[0].Checksum == migrations[0].Checksum {
+		t.Errorf("down file is not in checksum %v", err)
+	}
+
+	fsys["migrations/004_lost.down.sql"] = &fstest.MapFile{Data: []byte("SELECT 1;")}
+	if _, err := Load(fsys, "migrations"); err == nil {
+		t.Errorf("migration without up file accepted")
+	}
+	delete(fsys, "migrations/004_lost.down.sql")
+	fsys["migrations/000_init.up.sql"] = &fstest.MapFile{Data: []byte("SELECT 1;")}
+	if _, err := Load(fsys, "migrations"); err == nil {
+		t.Errorf("version 0 accepted")
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	script := "-- comment; not a statement\n" +
+		"CREATE TABLE `a;b` (`c` varchar(10) DEFAULT 'x;y');\n" +
+		"INSERT INTO `a;b` VALUES ('it\\'s; ok'); # tail; comment\n" +
+		";\n" +
+		"/* block; comment */ DELETE FROM `a;b`"
+	statements := SplitStatements(script, dbnames.DIALECTMYSQL)
+	if len(statements) != 3 {
+		t.Fatalf("bad statements %q", statements)
+	}
+	if statements[0] != "CREATE TABLE `a;b` (`c` varchar(10) DEFAULT 'x;y')" || statements[1] != "INSERT INTO `a;b` VALUES ('it\\'s; ok')" {
+		t.Errorf("bad statements %q", statements)
+	}
+
+	// в postgres # это оператор jsonb, а не комментарий
+	pg := SplitStatements("SELECT data #>> '{a,b}' FROM t; SELECT 5 # 3;", dbnames.DIALECTPOSTGRES)
+	if len(pg) != 2 || pg[0] != "SELECT data #>> '{a,b}' FROM t" || pg[1] != "SELECT 5 # 3" {
+		t.Errorf("bad postgres statements %q", pg)
+	}
+
+	// тела функций в долларах не делятся, параметры $1 это не метки
+	function := "CREATE FUNCTION touch() RETURNS trigger AS $$ BEGIN x := 1; RETURN NEW; END $$ LANGUAGE plpgsql;\n" +
+		"DO $body$ BEGIN PERFORM 1; END $body$;\n" +
+		"SELECT $1::int; SELECT 2"
+	pg = SplitStatements(function, dbnames.DIALECTPOSTGRES)
+	if len(pg) != 4 || pg[0] != "CREATE FUNCTION touch() RETURNS trigger AS $$ BEGIN x := 1; RETURN NEW; END $$ LANGUAGE plpgsql" ||
+		pg[1] != "DO $body$ BEGIN PERFORM 1; END $body$" || pg[2] != "SELECT $1::int" {
+		t.Errorf("bad dollar quoted statements %q", pg)
+	}
+
+	// в postgres \ в обычной строке не экранирует, а в E'...' экранирует
+	pg = SplitStatements("INSERT INTO p VALUES ('C:\\'); INSERT INTO p VALUES (E'it\\'s; ok'); SELECT 1", dbnames.DIALECTPOSTGRES)
+	if len(pg) != 3 || pg[0] != "INSERT INTO p VALUES ('C:\\')" || pg[1] != "INSERT INTO p VALUES (E'it\\'s; ok')" {
+		t.Errorf("bad postgres strings %q", pg)
+	}
+}
+
+var runnerFS = fstest.MapFS{
+	"migrations/001_auth.up.sql":   {Data: []byte("CREATE TABLE `auth` (`user_id` int unsigned NOT NULL);")},
+	"migrations/001_auth.down.sql": {Data: []byte("DROP TABLE `auth`;")},
+	"migrations/002_data.up.sql":   {Data: []byte("ALTER TABLE `auth` ADD COLUMN `data` varchar(255);\nUPDATE `auth` SET `data` = '';")},
+	"migrations/002_data.down.sql": {Data: []byte("ALTER TABLE `auth` DROP COLUMN `data`;")},
+}
+
+func expectStart(mock *dbnamestest.Mock, applied *dbnamestest.Rows) {
+	mock.ExpectQuery("SELECT GET_LOCK(?, ?)").WithArgs("schema_migrations", 60).WillReturnRows(dbnamestest.NewRows("lock").AddRow(1))
+	mock.ExpectExecRegexp("^CREATE TABLE IF NOT EXISTS `schema_migrations`")
+	mock.ExpectQuery("SELECT `version`, `name`, `checksum`, `applied_at` FROM `schema_migrations`").WillReturnRows(applied)
+}
+
+func TestMigrator(t *testing.T) {
+	db, mock, err := dbnamestest.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	m, err := New(db, dbnames.DIALECTMYSQL, runnerFS, "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := m.Migrations()[0]
+	columns := []string{"version", "name", "checksum", "applied_at"}
+
+	// первая применена, применяем вторую
+	expectStart(mock, dbnamestest.NewRows(columns...).AddRow(1, "auth", first.Checksum, "2023-05-12 21:41:23"))
+	mock.ExpectBegin()
+	mock.ExpectExec("ALTER TABLE `auth` ADD COLUMN `data` varchar(255)")
+	mock.ExpectExec("UPDATE `auth` SET `data` = ''")
+	mock.ExpectExec("INSERT INTO `schema_migrations` (`version`, `name`, `checksum`, `applied_at`) VALUES (?, ?, ?, CURRENT_TIMESTAMP)").
+		WithArgs(2, "data", m.Migrations()[1].Checksum)
+	mock.ExpectCommit()
+	mock.ExpectExec("SELECT RELEASE_LOCK(?)")
+	if err := m.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+
+	// откат последней
+	expectStart(mock, dbnamestest.NewRows(columns...).
+		AddRow(1, "auth", first.Checksum, "2023-05-12 21:41:23").
+		AddRow(2, "data", m.Migrations()[1].Checksum, "2023-05-12 21:41:24"))
+	mock.ExpectBegin()
+	mock.ExpectExec("ALTER TABLE `auth` DROP COLUMN `data`")
+	mock.ExpectExec("DELETE FROM `schema_migrations` WHERE `version`=2")
+	mock.ExpectCommit()
+	mock.ExpectExec("SELECT RELEASE_LOCK(?)")
+	if err := m.Down(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+
+	// файл примененной миграции изменили
+	expectStart(mock, dbnamestest.NewRows(columns...).AddRow(1, "auth", "0000", "2023-05-12 21:41:23"))
+	mock.ExpectExec("SELECT RELEASE_LOCK(?)")
+	if err := m.Goto(context.Background(), 2); err == nil {
+		t.Errorf("modified migration accepted")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectExecRegexp("^CREATE TABLE IF NOT EXISTS `schema_migrations`")
+	mock.ExpectQuery("SELECT `version`, `name`, `checksum`, `applied_at` FROM `schema_migrations`").
+		WillReturnRows(dbnamestest.NewRows(columns...).AddRow(1, "auth", "0000", "2023-05-12 21:41:23"))
+	status, err := m.Status(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(status) != 2 || !status[0].Applied || !status[0].Modified || status[1].Applied || status[0].AppliedAt.IsNULL() {
+		t.Errorf("bad status %+v", status)
+	}
+}
@@ -0,0 +1,151 @@
+package dbnames
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+/*
+столбец для сортировки: имя тега db и направление
+*/
+type sortField struct {
+	name string
+	desc bool
+}
+
+/*
+внутрення функция пакета, разбирает список сортировки вида "-create,crc" или
+"-create", "+crc": минус это DESC, плюс или без знака ASC. Каждое имя должно быть
+тегом db в структуре equal, иначе ошибка
+*/
+func parseSortFields(equal interface{}, sort []string) ([]sortField, error) {
+	known := dbFieldNames(reflect.TypeOf(equal))
+	sortFields := make([]sortField, 0, len(sort))
+	used := make(map[string]bool)
+	for _, param := range sort {
+		for _, item := range strings.Split(param, ",") {
+			item = strings.TrimSpace(item)
+			if len(item) == 0 {
+				continue
+			}
+			field := sortField{}
+			switch item[0] {
+			case '-':
+				field.desc = true
+				item = item[1:]
+			case '+':
+				item = item[1:]
+			}
+			if !known[item] {
+				return nil, fmt.Errorf("unknown field %q", item)
+			}
+			if used[item] {
+				return nil, fmt.Errorf("duplicate field %q", item)
+			}
+			used[item] = true
+			field.name = item
+			sortFields = append(sortFields, field)
+		}
+	}
+	return sortFields, nil
+}
+
+/*
+внутрення функция пакета, выдает множество тегов db структуры
+*/
+func dbFieldNames(ct reflect.Type) map[string]bool {
+	names := make(map[string]bool)
+	for i := 0; i < ct.NumField(); i++ {
+		if dbFieldName, find := ct.Field(i).Tag.Lookup("db"); find {
+			names[dbFieldName] = true
+		}
+	}
+	return names
+}
+
+/*
+функция формирует блок ORDER BY по списку тегов db структуры equal, например из
+параметра http запроса sort=-create,crc
+
+	BuildOrderBy("t", DBData{}, "-create,crc")
+	// ORDER BY `t`.`create` DESC, `t`.`crc` ASC
+
+имена которых нет в структуре не попадают в запрос, вместо этого выдается ошибка
+(та же идея что и с fields в BuildFields). Если сортировка не задана то пустая строка.
+*/
+func BuildOrderBy(table string, equal interface{}, sort ...string) (string, error) {
+	table = resolveTable(table, equal)
+	sortFields, err := parseSortFields(equal, sort)
+	if err != nil {
+		return "", err
+	}
+	if len(sortFields) == 0 {
+		return "", nil
+	}
+	orders := make([]string, 0, len(sortFields))
+	for _, field := range sortFields {
+		direction := "ASC"
+		if field.desc {
+			direction = "DESC"
+		}
+		orders = append(orders, fullFieldName(table, field.name)+" "+direction)
+	}
+	return "ORDER BY " + strings.Join(orders, ", "), nil
+}
+
+/*
+функция формирует блок GROUP BY по тегам db структуры equal, имена которых нет
+в структуре приводят к ошибке. Если поля не заданы то пустая строка.
+*/
+func BuildGroupBy(table string, equal interface{}, fields ...string) (string, error) {
+	table = resolveTable(table, equal)
+	if len(fields) == 0 {
+		return "", nil
+	}
+	known := dbFieldNames(reflect.TypeOf(equal))
+	for _, f := range fields {
+		if !known[f] {
+			return "", fmt.Errorf("unknown field %q", f)
+		}
+	}
+	return "GROUP BY " + strings.Join(BuildSortFields(table, equal, fields...), ", "), nil
+}
+
+/*
+функция соединяет условия (см BuildConditions, BuildFilter) через AND в блок HAVING,
+если условий нет то пустая строка
+*/
+func BuildHaving(conds []string) string {
+	if len(conds) == 0 {
+		return ""
+	}
+	return "HAVING" + strings.Join(conds, " AND")
+}
+
+/*
+функция формирует блок LIMIT/OFFSET, отрицательные значения приводят к ошибке,
+limit больше maxLimit (если он больше 0) урезается до maxLimit. Если limit равен 0
+то выдается пустая строка.
+*/
+func BuildLimit(limit int, offset int, maxLimit int) (string, error) {
+	if limit < 0 {
+		return "", fmt.Errorf("negative limit %d", limit)
+	}
+	if offset < 0 {
+		return "", fmt.Errorf("negative offset %d", offset)
+	}
+	if maxLimit > 0 && limit > maxLimit {
+		limit = maxLimit
+	}
+	if limit == 0 {
+		if offset > 0 {
+			return "", fmt.Errorf("offset %d without limit", offset)
+		}
+		return "", nil
+	}
+	if offset == 0 {
+		return fmt.Sprintf("LIMIT %d", limit), nil
+	}
+	return fmt.Sprintf("LIMIT %d OFFSET %d", limit, offset), nil
+}
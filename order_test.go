@@ -0,0 +1,50 @@
+package dbnames
+
+import (
+	"testing"
+)
+
+func TestBuildOrderBy(t *testing.T) {
+	order, err := BuildOrderBy("t", DBData{}, "-create,crc")
+	if err != nil {
+		t.Error(err)
+	} else if order != "ORDER BY `t`.`create` DESC, `t`.`crc` ASC" {
+		t.Errorf("bad order %s", order)
+	}
+	if order, err := BuildOrderBy("t", DBData{}, "+desc", "-crc"); err != nil || order != "ORDER BY `t`.`desc` ASC, `t`.`crc` DESC" {
+		t.Errorf("bad order %s %v", order, err)
+	}
+	if order, err := BuildOrderBy("t", DBData{}); err != nil || order != "" {
+		t.Errorf("bad empty order %s %v", order, err)
+	}
+	// поля нет в структуре
+	if _, err := BuildOrderBy("t", DBData{}, "crc;DROP TABLE t"); err == nil {
+		t.Errorf("unknown field accepted")
+	}
+	if _, err := BuildOrderBy("t", DBData{}, "NotFound"); err == nil {
+		t.Errorf("field without tag accepted")
+	}
+	if _, err := BuildOrderBy("t", DBData{}, "crc,-crc"); err == nil {
+		t.Errorf("duplicate field accepted")
+	}
+
+	group, err := BuildGroupBy("t", DBData{}, "desc", "crc")
+	if err != nil || group != "GROUP BY `t`.`desc`, `t`.`crc`" {
+		t.Errorf("bad group %s %v", group, err)
+	}
+	if _, err := BuildGroupBy("t", DBData{}, "name"); err == nil {
+		t.Errorf("unknown group field accepted")
+	}
+
+	having := BuildHaving(BuildConditions("t", DBData{Crc: 10}, MORE))
+	if having != "HAVING `t`.`crc`>10" {
+		t.Errorf("bad having %s", having)
+	}
+
+	if limit, err := BuildLimit(1000, 20, 100); err != nil || limit != "LIMIT 100 OFFSET 20" {
+		t.Errorf("bad limit %s %v", limit, err)
+	}
+	if _, err := BuildLimit(10, -1, 100); err == nil {
+		t.Errorf("negative offset accepted")
+	}
+}
@@ -0,0 +1,435 @@
+package dbnames
+
+import (
+	"fmt"
+	"strings"
+)
+
+// эта часть разбирает вывод SHOW CREATE TABLE (или дамп) в описание таблицы Table
+
+const (
+	tokWord = iota
+	tokIdent
+	tokString
+	tokPunct
+)
+
+type token struct {
+	kind int
+	text string // значение: слово, имя без кавычек, строка без кавычек
+	raw  string // как было записано в тексте
+}
+
+func (t token) is(word string) bool {
+	return t.kind == tokWord && strings.EqualFold(t.text, word)
+}
+
+func (t token) punct(p string) bool {
+	return t.kind == tokPunct && t.text == p
+}
+
+// внутрення функция пакета, разбивает текст на лексемы, комментарии пропускаются
+// (в том числе условные /*!40101 ... */ из mysqldump)
+func tokenize(ddl string) ([]token, error) {
+	tokens := make([]token, 0, len(ddl)/4)
+	for i := 0; i < len(ddl); {
+		c := ddl[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '#' || (c == '-' && strings.HasPrefix(ddl[i:], "-- ")):
+			for i < len(ddl) && ddl[i] != '\n' {
+				i++
+			}
+		case c == '/' && strings.HasPrefix(ddl[i:], "/*"):
+			end := strings.Index(ddl[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated comment")
+			}
+			i += end + 4
+		case c == '`' || c == '"':
+			var sb strings.Builder
+			j := i + 1
+			for ; j < len(ddl); j++ {
+				if ddl[j] == c {
+					if j+1 < len(ddl) && ddl[j+1] == c {
+						sb.WriteByte(c)
+						j++
+						continue
+					}
+					break
+				}
+				sb.WriteByte(ddl[j])
+			}
+			if j >= len(ddl) {
+				return nil, fmt.Errorf("unterminated identifier")
+			}
+			tokens = append(tokens, token{kind: tokIdent, text: sb.String(), raw: ddl[i : j+1]})
+			i = j + 1
+		case c == '\'':
+			var sb strings.Builder
+			j := i + 1
+			for ; j < len(ddl); j++ {
+				if ddl[j] == '\\' && j+1 < len(ddl) {
+					j++
+					sb.WriteByte(ddl[j])
+					continue
+				}
+				if ddl[j] == '\'' {
+					if j+1 < len(ddl) && ddl[j+1] == '\'' {
+						sb.WriteByte('\'')
+						j++
+						continue
+					}
+					break
+				}
+				sb.WriteByte(ddl[j])
+			}
+			if j >= len(ddl) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			tokens = append(tokens, token{kind: tokString, text: sb.String(), raw: ddl[i : j+1]})
+			i = j + 1
+		case strings.IndexByte("(),;=.", c) >= 0:
+			tokens = append(tokens, token{kind: tokPunct, text: string(c), raw: string(c)})
+			i++
+		default:
+			j := i
+			for j < len(ddl) && strings.IndexByte(" \t\n\r(),;=`\"'", ddl[j]) < 0 {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokWord, text: ddl[i:j], raw: ddl[i:j]})
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+type ddlParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *ddlParser) eof() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *ddlParser) peek() token {
+	if p.eof() {
+		return token{kind: tokPunct}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *ddlParser) next() token {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *ddlParser) accept(words ...string) bool {
+	if p.pos+len(words) > len(p.tokens) {
+		return false
+	}
+	for i, word := range words {
+		if !p.tokens[p.pos+i].is(word) {
+			return false
+		}
+	}
+	p.pos += len(words)
+	return true
+}
+
+func (p *ddlParser) name() (string, error) {
+	t := p.next()
+	if t.kind != tokIdent && t.kind != tokWord {
+		return "", fmt.Errorf("expected name, got %q", t.raw)
+	}
+	return t.text, nil
+}
+
+/*
+пропускает выражение до запятой или закрывающей скобки на текущем уровне вложенности
+*/
+func (p *ddlParser) skip() {
+	depth := 0
+	for !p.eof() {
+		t := p.peek()
+		if depth == 0 && (t.punct(",") || t.punct(")") || t.punct(";")) {
+			return
+		}
+		if t.punct("(") {
+			depth++
+		} else if t.punct(")") {
+			depth--
+		}
+		p.pos++
+	}
+}
+
+/*
+текст в скобках как был записан, например (10) или ('a','b') для enum
+*/
+func (p *ddlParser) parens() (string, error) {
+	if !p.peek().punct("(") {
+		return "", nil
+	}
+	var sb strings.Builder
+	depth := 0
+	for !p.eof() {
+		t := p.next()
+		sb.WriteString(t.raw)
+		if t.punct("(") {
+			depth++
+		} else if t.punct(")") {
+			depth--
+			if depth == 0 {
+				return sb.String(), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("unterminated parens")
+}
+
+/*
+список столбцов индекса: (`a`,`b`(10) DESC)
+*/
+func (p *ddlParser) columnList() ([]string, error) {
+	if !p.next().punct("(") {
+		return nil, fmt.Errorf("expected column list")
+	}
+	columns := make([]string, 0)
+	for {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		columns = append(columns, name)
+		p.skip()
+		t := p.next()
+		if t.punct(")") {
+			return columns, nil
+		}
+		if !t.punct(",") {
+			return nil, fmt.Errorf("bad column list near %q", t.raw)
+		}
+	}
+}
+
+/*
+функция разбирает один CREATE TABLE в синтаксисе MySQL (вывод SHOW CREATE TABLE
+или mysqldump), см ParseCreateTables
+*/
+func ParseCreateTable(ddl string) (*Table, error) {
+	tables, err := ParseCreateTables(ddl)
+	if err != nil {
+		return nil, err
+	}
+	if len(tables) != 1 {
+		return nil, fmt.Errorf("expected one table, got %d", len(tables))
+	}
+	return tables[0], nil
+}
+
+/*
+функция находит в тексте все CREATE TABLE и разбирает их, остальные
+выражения (INSERT, SET и т.п.) пропускаются
+*/
+func ParseCreateTables(ddl string) ([]*Table, error) {
+	tokens, err := tokenize(ddl)
+	if err != nil {
+		return nil, err
+	}
+	p := &ddlParser{tokens: tokens}
+	tables := make([]*Table, 0)
+	for !p.eof() {
+		if p.accept("CREATE", "TABLE") || p.accept("CREATE", "TEMPORARY", "TABLE") {
+			table, err := p.createTable()
+			if err != nil {
+				return nil, err
+			}
+			tables = append(tables, table)
+			continue
+		}
+		// пропускаем выражение до точки с запятой
+		for !p.eof() && !p.next().punct(";") {
+		}
+	}
+	return tables, nil
+}
+
+func (p *ddlParser) createTable() (*Table, error) {
+	p.accept("IF", "NOT", "EXISTS")
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().punct(".") {
+		p.next()
+		if name, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+	table := &Table{Name: name}
+	if !p.next().punct("(") {
+		return nil, fmt.Errorf("table %s: expected (", name)
+	}
+	for {
+		if err := p.definition(table); err != nil {
+			return nil, fmt.Errorf("table %s: %w", name, err)
+		}
+		t := p.next()
+		if t.punct(")") {
+			break
+		}
+		if !t.punct(",") {
+			return nil, fmt.Errorf("table %s: unexpected %q", name, t.raw)
+		}
+	}
+	// параметры таблицы до конца выражения, лишняя закрывающая скобка значит что
+	// определения разобраны не до конца и часть столбцов потерялась бы молча
+	depth := 0
+	for !p.eof() && !p.peek().punct(";") {
+		t := p.next()
+		switch {
+		case t.punct("("):
+			depth++
+		case t.punct(")"):
+			if depth == 0 {
+				return nil, fmt.Errorf("table %s: unexpected ) after definitions", name)
+			}
+			depth--
+		case t.is("ENGINE"):
+			table.Engine = p.optionValue()
+		case t.is("CHARSET"):
+			table.Charset = p.optionValue()
+		case t.is("CHARACTER") && p.accept("SET"):
+			table.Charset = p.optionValue()
+		case t.is("COLLATE"):
+			table.Collate = p.optionValue()
+		}
+	}
+	p.next()
+	return table, nil
+}
+
+func (p *ddlParser) optionValue() string {
+	if p.peek().punct("=") {
+		p.next()
+	}
+	return p.next().text
+}
+
+func (p *ddlParser) definition(table *Table) error {
+	if p.accept("CONSTRAINT") {
+		if t := p.peek(); t.kind == tokIdent || (t.kind == tokWord && !t.is("PRIMARY") && !t.is("UNIQUE") && !t.is("FOREIGN") && !t.is("CHECK")) {
+			p.next()
+		}
+	}
+	switch {
+	case p.accept("PRIMARY", "KEY"):
+		if !p.peek().punct("(") {
+			p.next()
+		}
+		columns, err := p.columnList()
+		if err != nil {
+			return err
+		}
+		table.PrimaryKey = columns
+		p.skip()
+	case p.peek().is("UNIQUE") || p.peek().is("KEY") || p.peek().is("INDEX"):
+		index := Index{Unique: p.accept("UNIQUE")}
+		if !p.accept("KEY") {
+			p.accept("INDEX")
+		}
+		if !p.peek().punct("(") {
+			name, err := p.name()
+			if err != nil {
+				return err
+			}
+			index.Name = name
+		}
+		columns, err := p.columnList()
+		if err != nil {
+			return err
+		}
+		index.Columns = columns
+		if len(index.Name) == 0 {
+			index.Name = columns[0]
+		}
+		table.Indexes = append(table.Indexes, index)
+		p.skip()
+	case p.peek().is("FOREIGN") || p.peek().is("FULLTEXT") || p.peek().is("SPATIAL") || p.peek().is("CHECK"):
+		p.skip()
+	default:
+		column, err := p.column(table)
+		if err != nil {
+			return err
+		}
+		table.Columns = append(table.Columns, column)
+	}
+	return nil
+}
+
+func (p *ddlParser) column(table *Table) (Column, error) {
+	name, err := p.name()
+	if err != nil {
+		return Column{}, err
+	}
+	column := Column{Name: name, Null: true}
+	t := p.next()
+	if t.kind != tokWord {
+		return Column{}, fmt.Errorf("column %s: expected type, got %q", name, t.raw)
+	}
+	args, err := p.parens()
+	if err != nil {
+		return Column{}, err
+	}
+	column.Type = t.text + args
+	for p.peek().is("UNSIGNED") || p.peek().is("ZEROFILL") || p.peek().is("SIGNED") {
+		column.Type += " " + p.next().text
+	}
+	for !p.eof() {
+		t := p.peek()
+		if t.punct(",") || t.punct(")") {
+			break
+		}
+		switch {
+		case p.accept("NOT", "NULL"):
+			column.Null = false
+		case p.accept("NULL"):
+			column.Null = true
+		case p.accept("DEFAULT"):
+			// выражение в скобках (MySQL 8): DEFAULT (uuid()) берется целиком
+			var value string
+			if !p.peek().punct("(") {
+				value = p.next().raw
+			}
+			args, err := p.parens()
+			if err != nil {
+				return Column{}, fmt.Errorf("column %s: default: %w", name, err)
+			}
+			value += args
+			if len(value) == 0 {
+				return Column{}, fmt.Errorf("column %s: empty default", name)
+			}
+			column.Default = &value
+		case p.accept("AUTO_INCREMENT"):
+			column.AutoIncrement = true
+		case p.accept("PRIMARY", "KEY"):
+			table.PrimaryKey = []string{name}
+		case p.accept("UNIQUE"):
+			p.accept("KEY")
+			table.Indexes = append(table.Indexes, Index{Name: name, Columns: []string{name}, Unique: true})
+		case p.accept("ON", "UPDATE"):
+			p.next()
+			p.parens()
+		case p.accept("CHARACTER", "SET"), p.accept("COLLATE"), p.accept("COMMENT"):
+			p.next()
+		default:
+			p.next()
+			p.parens()
+		}
+	}
+	return column, nil
+}
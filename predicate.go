@@ -0,0 +1,180 @@
+package dbnames
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+/*
+условие на один столбец: Column оператор Values, обычно создается через Col
+*/
+type Predicate struct {
+	Column    string
+	Operation Operation
+	Values    []interface{}
+}
+
+/*
+типизированный столбец, dbnames-gen -src генерирует их для каждой структуры,
+поэтому опечатка в имени столбца становится ошибкой компиляции
+
+	cond, err := BuildPredicates(DBAuthTable, AuthCols.UserId.Eq(5), AuthCols.Create.Gt(t))
+	fields := BuildFields(DBAuthTable, DBAuth{}, Names(AuthCols.UserId, AuthCols.Create)...)
+*/
+type Col[T any] struct {
+	name string
+}
+
+func NewCol[T any](name string) Col[T] {
+	return Col[T]{name: name}
+}
+
+/*
+тег db столбца
+*/
+func (c Col[T]) Name() string {
+	return c.name
+}
+
+func (c Col[T]) predicate(operation Operation, values ...T) Predicate {
+	res := Predicate{Column: c.name, Operation: operation, Values: make([]interface{}, 0, len(values))}
+	for _, value := range values {
+		res.Values = append(res.Values, value)
+	}
+	return res
+}
+
+func (c Col[T]) Eq(value T) Predicate {
+	return c.predicate(EQUAL, value)
+}
+
+func (c Col[T]) Ne(value T) Predicate {
+	return c.predicate(NOTEQ, value)
+}
+
+func (c Col[T]) Lt(value T) Predicate {
+	return c.predicate(LESS, value)
+}
+
+func (c Col[T]) Lte(value T) Predicate {
+	return c.predicate(LESSEQ, value)
+}
+
+func (c Col[T]) Gt(value T) Predicate {
+	return c.predicate(MORE, value)
+}
+
+func (c Col[T]) Gte(value T) Predicate {
+	return c.predicate(MOREQE, value)
+}
+
+func (c Col[T]) In(values ...T) Predicate {
+	return c.predicate(IN, values...)
+}
+
+func (c Col[T]) NotIn(values ...T) Predicate {
+	return c.predicate(NOTIN, values...)
+}
+
+func (c Col[T]) IsNull() Predicate {
+	return c.predicate(ISNULL)
+}
+
+func (c Col[T]) IsNotNull() Predicate {
+	return c.predicate(ISNOTNULL)
+}
+
+func (c Col[T]) Like(pattern string) Predicate {
+	return Predicate{Column: c.name, Operation: LIKE, Values: []interface{}{pattern}}
+}
+
+func (c Col[T]) NotLike(pattern string) Predicate {
+	return Predicate{Column: c.name, Operation: NOTLIKE, Values: []interface{}{pattern}}
+}
+
+/*
+имена столбцов для BuildFields и BuildSortFields
+*/
+func Names(cols ...interface{ Name() string }) []string {
+	names := make([]string, 0, len(cols))
+	for _, col := range cols {
+		names = append(names, col.Name())
+	}
+	return names
+}
+
+/*
+функция генерирует условия для блока WHERE из предикатов в том же виде что и BuildConditions,
+IN и NOTIN без значений это ошибка
+*/
+func BuildPredicates(table string, preds ...Predicate) ([]string, error) {
+	fillCond := make([]string, 0, len(preds))
+	for _, pred := range preds {
+		cond, err := pred.render(table)
+		if err != nil {
+			return nil, err
+		}
+		fillCond = append(fillCond, cond)
+	}
+	return fillCond, nil
+}
+
+func (pred Predicate) render(table string) (string, error) {
+	if err := pred.check(); err != nil {
+		return "", err
+	}
+	fullField := fullFieldName(table, pred.Column)
+	if pred.Operation == ISNULL || pred.Operation == ISNOTNULL {
+		return " " + fullField + pred.Operation.ToString(""), nil
+	}
+	literals := ""
+	for i, value := range pred.Values {
+		literal, ok := valueLiteral(reflect.ValueOf(value))
+		if !ok {
+			return "", fmt.Errorf("field %s: type %T has no sql representation", pred.Column, value)
+		}
+		if i > 0 {
+			literals += ", "
+		}
+		literals += literal
+	}
+	return " " + fullField + pred.Operation.ToString(literals), nil
+}
+
+/*
+то же что render, но вместо значений параметры диалекта, n номер последнего
+уже использованного параметра (для $n в postgres)
+*/
+func (pred Predicate) renderPlaceholders(table string, dialect Dialect, n *int) (string, error) {
+	if err := pred.check(); err != nil {
+		return "", err
+	}
+	fullField := qualifiedName(dialect, table, pred.Column)
+	if pred.Operation == ISNULL || pred.Operation == ISNOTNULL {
+		return " " + fullField + pred.Operation.ToString(""), nil
+	}
+	placeholders := make([]string, 0, len(pred.Values))
+	for range pred.Values {
+		*n++
+		placeholders = append(placeholders, dialect.Placeholder(*n))
+	}
+	return " " + fullField + pred.Operation.ToString(strings.Join(placeholders, ", ")), nil
+}
+
+func (pred Predicate) check() error {
+	switch pred.Operation {
+	case ISNULL, ISNOTNULL:
+	case IN, NOTIN:
+		if len(pred.Values) == 0 {
+			return fmt.Errorf("field %s: empty list", pred.Column)
+		}
+	case UNDEF:
+		return fmt.Errorf("field %s: undefined operation", pred.Column)
+	default:
+		if len(pred.Values) != 1 {
+			return fmt.Errorf("field %s: need one value, got %d", pred.Column, len(pred.Values))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package dbnames
+
+import (
+	"testing"
+)
+
+func TestBuildPredicates(t *testing.T) {
+	create := MYSQLDATETIME{}
+	create.FromString("2023-05-12 21:41:23")
+	cond, err := BuildPredicates(DBAuthTable,
+		AuthCols.UserId.Eq(5),
+		AuthCols.Create.Gt(create),
+		AuthCols.Type.In(PhoneType, EmailType),
+		AuthCols.Data.Like("%Denis%"),
+		AuthCols.Data.IsNotNull(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		" `auth`.`user_id`=5",
+		" `auth`.`create`>'2023-05-12 21:41:23'",
+		" `auth`.`auth_type` IN (1, 2)",
+		" `auth`.`data` LIKE '%Denis%'",
+		" `auth`.`data` IS NOT NULL",
+	}
+	if len(cond) != len(expected) {
+		t.Fatalf("bad conditions %v", cond)
+	}
+	for i := range expected {
+		if cond[i] != expected[i] {
+			t.Errorf("bad condition %s, expected %s", cond[i], expected[i])
+		}
+	}
+
+	if _, err := BuildPredicates(DBAuthTable, AuthCols.Type.In()); err == nil {
+		t.Errorf("empty in accepted")
+	}
+
+	fields := BuildSortFields(DBAuthTable, DBAuth{}, Names(AuthCols.Create, AuthCols.UserId)...)
+	if len(fields) != 2 || fields[0] != "`auth`.`create`" || fields[1] != "`auth`.`user_id`" {
+		t.Errorf("bad fields %v", fields)
+	}
+}
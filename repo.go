@@ -0,0 +1,451 @@
+package dbnames
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// эта часть файла дает типовые Get/Find/Insert/Update/Delete для структуры с тегами db
+
+/*
+параметры Find: сортировка в формате BuildOrderBy ("-create,user_id"), LIMIT и OFFSET
+(0 без ограничения) и список столбцов (пустой это все столбцы T)
+*/
+type FindOptions struct {
+	OrderBy string
+	Limit   int
+	Offset  int
+	Fields  []string
+}
+
+/*
+репозиторий таблицы table для структуры T: первичный ключ берется из полей с dbddl:"pk",
+поле pk с autoinc при нулевом значении не вставляется, а заполняется из базы,
+запросы идут в транзакцию InTx если она есть в контексте, иначе в Querier репозитория,
+с TABLEAUTO имя берется из модели (см ModelTable): Insert, Update и Upsert считают его
+по каждой строке, остальные методы по нулевому T, другую таблицу задает InTable.
+Если для T зарегистрирован TableResolver, то имя зависит от строки и Get, Find, Count,
+Exists и Delete без InTable возвращают ошибку с кодом BDERRORPARAM
+
+	sessions, err := dbnames.NewRepo[DBSession](db, DBSessionTable, dbnames.DIALECTMYSQL)
+	session, err := sessions.Get(ctx, id)
+	err = dbnames.InTx(ctx, db, nil, func(ctx context.Context, tx *dbnames.Tx) error {
+		_, err := sessions.Delete(ctx, id)
+		return err
+	})
+*/
+type Repo[T any] struct {
+	q       Querier
+	table   string
+	auto    bool
+	dialect Dialect
+	columns []string
+	fields  []int
+	pk      []int
+	autoinc int
+}
+
+/*
+функция создает репозиторий, ошибка если T не структура или у нее нет dbddl:"pk"
+*/
+func NewRepo[T any](q Querier, table string, dialect Dialect) (*Repo[T], error) {
+	model := reflect.TypeOf((*T)(nil)).Elem()
+	if model.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("model %s is not a struct", model)
+	}
+	repo := &Repo[T]{q: q, table: table, dialect: dialect, autoinc: -1}
+	if table == TABLEAUTO {
+		var zero T
+		repo.auto = true
+		repo.table = resolveTable(table, zero)
+		if len(repo.table) == 0 {
+			return nil, fmt.Errorf("no table name for %s", model)
+		}
+	}
+	for i := 0; i < model.NumField(); i++ {
+		field := model.Field(i)
+		dbFieldName, find := field.Tag.Lookup("db")
+		if !find {
+			continue
+		}
+		options, _ := field.Tag.Lookup("dbddl")
+		for _, option := range splitDDLOptions(options) {
+			switch option {
+			case "pk":
+				repo.pk = append(repo.pk, len(repo.columns))
+			case "autoinc":
+				repo.autoinc = len(repo.columns)
+			}
+		}
+		repo.columns = append(repo.columns, dbFieldName)
+		repo.fields = append(repo.fields, i)
+	}
+	if len(repo.pk) == 0 {
+		return nil, fmt.Errorf("model %s has no dbddl:\"pk\" fields", model)
+	}
+	return repo, nil
+}
+
+// With возвращает копию репозитория которая работает через q, например *sql.Tx
+func (r *Repo[T]) With(q Querier) *Repo[T] {
+	copied := *r
+	copied.q = q
+	return &copied
+}
+
+// InTable возвращает копию репозитория для таблицы table, например другого шарда
+func (r *Repo[T]) InTable(table string) *Repo[T] {
+	copied := *r
+	copied.table = table
+	copied.auto = false
+	return &copied
+}
+
+/*
+внутрення функция пакета, таблица для записи строки row
+*/
+func (r *Repo[T]) tableOf(row *T) string {
+	if r.auto {
+		if name, ok := ModelTable(*row); ok && len(name) > 0 {
+			return name
+		}
+	}
+	return r.table
+}
+
+/*
+внутрення функция пакета, таблица для запросов без строки (по ключу или условиям)
+*/
+func (r *Repo[T]) keyTable() (string, error) {
+	model := reflect.TypeOf((*T)(nil)).Elem()
+	if _, ok := tableResolvers.Load(model); ok && r.auto {
+		return "", &Error{Code: BDERRORPARAM, Err: fmt.Errorf("table of %s depends on the row, use InTable", model)}
+	}
+	return r.table, nil
+}
+
+func (r *Repo[T]) querier(ctx context.Context) Querier {
+	if tx, ok := TxFromContext(ctx); ok {
+		return tx
+	}
+	return r.q
+}
+
+/*
+внутрення функция пакета, условия на первичный ключ, значения в порядке полей pk
+*/
+func (r *Repo[T]) pkPredicates(pk []interface{}) ([]Predicate, error) {
+	if len(pk) != len(r.pk) {
+		return nil, fmt.Errorf("table %s: primary key has %d columns, got %d values", r.table, len(r.pk), len(pk))
+	}
+	preds := make([]Predicate, 0, len(pk))
+	for i, column := range r.pk {
+		preds = append(preds, Predicate{Column: r.columns[column], Operation: EQUAL, Values: []interface{}{pk[i]}})
+	}
+	return preds, nil
+}
+
+func (r *Repo[T]) isPK(column int) bool {
+	for _, pk := range r.pk {
+		if pk == column {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+строка по первичному ключу, если ее нет то ошибка с кодом BDERRORUSERNOTFOUND
+*/
+func (r *Repo[T]) Get(ctx context.Context, pk ...interface{}) (T, error) {
+	var row T
+	table, err := r.keyTable()
+	if err != nil {
+		return row, err
+	}
+	query, err := SelectByPK[T](table, r.dialect)
+	if err != nil {
+		return row, &Error{Code: BDERRORPARAM, Err: err}
+	}
+	if _, err := r.pkPredicates(pk); err != nil {
+		return row, &Error{Code: BDERRORPARAM, Err: err}
+	}
+	err = QueryRow(ctx, r.querier(ctx), Raw(query, pk...), &row)
+	return row, err
+}
+
+/*
+строки по условиям where (через AND), opts может быть nil
+*/
+func (r *Repo[T]) Find(ctx context.Context, where []Predicate, opts *FindOptions) ([]T, error) {
+	if opts == nil {
+		opts = &FindOptions{}
+	}
+	table, err := r.keyTable()
+	if err != nil {
+		return nil, err
+	}
+	var model T
+	query, args, err := BuildSelect(table, model, r.dialect, opts.Fields, where...)
+	if err != nil {
+		return nil, &Error{Code: BDERRORPARAM, Err: err}
+	}
+	if len(opts.OrderBy) > 0 {
+		sortFields, err := parseSortFields(model, []string{opts.OrderBy})
+		if err != nil {
+			return nil, &Error{Code: BDERRORPARAM, Err: err}
+		}
+		order := make([]string, 0, len(sortFields))
+		for _, field := range sortFields {
+			direction := " ASC"
+			if field.desc {
+				direction = " DESC"
+			}
+			order = append(order, qualifiedName(r.dialect, table, field.name)+direction)
+		}
+		query += " ORDER BY " + strings.Join(order, ", ")
+	}
+	limit, err := BuildLimit(opts.Limit, opts.Offset, 0)
+	if err != nil {
+		return nil, &Error{Code: BDERRORPARAM, Err: err}
+	}
+	if len(limit) > 0 {
+		query += " " + limit
+	}
+	rows := make([]T, 0)
+	for row, err := range Rows[T](ctx, r.querier(ctx), query, args...) {
+		if err != nil {
+			return nil, Classify(err)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+/*
+число строк по условиям where
+*/
+func (r *Repo[T]) Count(ctx context.Context, where ...Predicate) (int64, error) {
+	table, err := r.keyTable()
+	if err != nil {
+		return 0, err
+	}
+	whereSQL, err := renderWhere(table, r.dialect, where)
+	if err != nil {
+		return 0, &Error{Code: BDERRORPARAM, Err: err}
+	}
+	res, err := Query(ctx, r.querier(ctx), Raw("SELECT COUNT(*) FROM "+r.dialect.Quote(table)+whereSQL, predicateArgs(where)...))
+	if err != nil {
+		return 0, err
+	}
+	defer res.Close()
+	if !res.Next() {
+		return 0, res.Err()
+	}
+	if err := res.Scan(); err != nil {
+		return 0, Classify(err)
+	}
+	raw, _ := res.GetRawBytes(0)
+	count, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("bad count %q: %w", raw, err)
+	}
+	return count, nil
+}
+
+/*
+есть ли хоть одна строка по условиям where
+*/
+func (r *Repo[T]) Exists(ctx context.Context, where ...Predicate) (bool, error) {
+	table, err := r.keyTable()
+	if err != nil {
+		return false, err
+	}
+	whereSQL, err := renderWhere(table, r.dialect, where)
+	if err != nil {
+		return false, &Error{Code: BDERRORPARAM, Err: err}
+	}
+	res, err := Query(ctx, r.querier(ctx), Raw("SELECT 1 FROM "+r.dialect.Quote(table)+whereSQL+" LIMIT 1", predicateArgs(where)...))
+	if err != nil {
+		return false, err
+	}
+	defer res.Close()
+	found := res.Next()
+	return found, res.Err()
+}
+
+/*
+внутрення функция пакета, столбцы и значения для INSERT, autoinc с нулевым значением пропускается
+*/
+func (r *Repo[T]) insertValues(value reflect.Value) ([]string, []string, []interface{}, bool) {
+	columns := make([]string, 0, len(r.columns))
+	placeholders := make([]string, 0, len(r.columns))
+	args := make([]interface{}, 0, len(r.columns))
+	generated := false
+	for i, column := range r.columns {
+		field := value.Field(r.fields[i])
+		if i == r.autoinc && field.IsZero() {
+			generated = true
+			continue
+		}
+		columns = append(columns, r.dialect.Quote(column))
+		args = append(args, field.Interface())
+		placeholders = append(placeholders, r.dialect.Placeholder(len(args)))
+	}
+	return columns, placeholders, args, generated
+}
+
+/*
+вставляет строку, значение autoinc поля (если оно было нулевым) записывается в row
+*/
+func (r *Repo[T]) Insert(ctx context.Context, row *T) error {
+	value := reflect.ValueOf(row).Elem()
+	table := r.tableOf(row)
+	columns, placeholders, args, generated := r.insertValues(value)
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", r.dialect.Quote(table), strings.Join(columns, ", "), strings.Join(placeholders, ", "))
+	return r.insert(ctx, value, query, args, generated)
+}
+
+func (r *Repo[T]) insert(ctx context.Context, value reflect.Value, query string, args []interface{}, generated bool) error {
+	if generated && r.dialect == DIALECTPOSTGRES {
+		// LastInsertId в postgres не работает, id возвращает сам INSERT
+		field := value.Field(r.fields[r.autoinc])
+		query += " RETURNING " + r.dialect.Quote(r.columns[r.autoinc])
+		ctx, cancel := withDefaultTimeout(ctx)
+		defer cancel()
+		err := r.querier(ctx).QueryRowContext(ctx, query, args...).Scan(field.Addr().Interface())
+		if errors.Is(err, sql.ErrNoRows) {
+			// ON CONFLICT DO NOTHING ничего не вставил, это не ошибка
+			return nil
+		}
+		return Classify(err)
+	}
+	result, err := Exec(ctx, r.querier(ctx), Raw(query, args...))
+	if err != nil || !generated {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		// INSERT IGNORE пропустил строку, id нет
+		return nil
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return Classify(err)
+	}
+	if id == 0 {
+		return nil
+	}
+	field := value.Field(r.fields[r.autoinc])
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		field.SetInt(id)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		field.SetUint(uint64(id))
+	}
+	return nil
+}
+
+/*
+обновляет все столбцы кроме первичного ключа у строки с ключом из row, возвращает
+число измененных строк (0 если строки нет или значения не изменились)
+*/
+func (r *Repo[T]) Update(ctx context.Context, row *T) (int64, error) {
+	value := reflect.ValueOf(row).Elem()
+	table := r.tableOf(row)
+	set := make([]string, 0, len(r.columns))
+	args := make([]interface{}, 0, len(r.columns))
+	for i, column := range r.columns {
+		if r.isPK(i) {
+			continue
+		}
+		args = append(args, value.Field(r.fields[i]).Interface())
+		set = append(set, r.dialect.Quote(column)+" = "+r.dialect.Placeholder(len(args)))
+	}
+	if len(set) == 0 {
+		return 0, &Error{Code: BDERRORPARAM, Err: fmt.Errorf("table %s: nothing to update", r.table)}
+	}
+	where := make([]string, 0, len(r.pk))
+	for _, column := range r.pk {
+		args = append(args, value.Field(r.fields[column]).Interface())
+		where = append(where, qualifiedName(r.dialect, table, r.columns[column])+"="+r.dialect.Placeholder(len(args)))
+	}
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s", r.dialect.Quote(table), strings.Join(set, ", "), strings.Join(where, " AND "))
+	result, err := Exec(ctx, r.querier(ctx), Raw(query, args...))
+	if err != nil {
+		return 0, err
+	}
+	affected, err := result.RowsAffected()
+	return affected, Classify(err)
+}
+
+/*
+удаляет строку по первичному ключу, возвращает число удаленных строк
+*/
+func (r *Repo[T]) Delete(ctx context.Context, pk ...interface{}) (int64, error) {
+	preds, err := r.pkPredicates(pk)
+	if err != nil {
+		return 0, &Error{Code: BDERRORPARAM, Err: err}
+	}
+	table, err := r.keyTable()
+	if err != nil {
+		return 0, err
+	}
+	where, err := renderWhere(table, r.dialect, preds)
+	if err != nil {
+		return 0, &Error{Code: BDERRORPARAM, Err: err}
+	}
+	result, err := Exec(ctx, r.querier(ctx), Raw("DELETE FROM "+r.dialect.Quote(table)+where, pk...))
+	if err != nil {
+		return 0, err
+	}
+	affected, err := result.RowsAffected()
+	return affected, Classify(err)
+}
+
+/*
+вставляет строку, а если строка с таким ключом уже есть то обновляет ее столбцы:
+ON DUPLICATE KEY UPDATE в MySQL и ON CONFLICT DO UPDATE в postgres и SQLite,
+если кроме ключа столбцов нет то существующая строка остается как есть (INSERT IGNORE
+и ON CONFLICT DO NOTHING), autoinc поле row тогда не меняется
+*/
+func (r *Repo[T]) Upsert(ctx context.Context, row *T) error {
+	value := reflect.ValueOf(row).Elem()
+	table := r.tableOf(row)
+	columns, placeholders, args, generated := r.insertValues(value)
+	update := make([]string, 0, len(r.columns))
+	for i, column := range r.columns {
+		if r.isPK(i) {
+			continue
+		}
+		quoted := r.dialect.Quote(column)
+		if r.dialect == DIALECTMYSQL {
+			update = append(update, quoted+" = VALUES("+quoted+")")
+		} else {
+			update = append(update, quoted+" = EXCLUDED."+quoted)
+		}
+	}
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", r.dialect.Quote(table), strings.Join(columns, ", "), strings.Join(placeholders, ", "))
+	switch {
+	case r.dialect == DIALECTMYSQL && len(update) > 0:
+		query += " ON DUPLICATE KEY UPDATE " + strings.Join(update, ", ")
+	case r.dialect == DIALECTMYSQL:
+		query = strings.Replace(query, "INSERT", "INSERT IGNORE", 1)
+	default:
+		pk := make([]string, 0, len(r.pk))
+		for _, column := range r.pk {
+			pk = append(pk, r.dialect.Quote(r.columns[column]))
+		}
+		query += " ON CONFLICT (" + strings.Join(pk, ", ") + ")"
+		if len(update) > 0 {
+			query += " DO UPDATE SET " + strings.Join(update, ", ")
+		} else {
+			query += " DO NOTHING"
+		}
+	}
+	return r.insert(ctx, value, query, args, generated)
+}
@@ -0,0 +1,147 @@
+package dbnames
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/denisbdn/dbnames/dbnamestest"
+)
+
+func TestRepo(t *testing.T) {
+	db, mock, err := dbnamestest.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	if _, err := NewRepo[DBAuth](db, DBAuthTable, DIALECTMYSQL); err == nil {
+		t.Errorf("struct without pk accepted")
+	}
+	sessions, err := NewRepo[DBSession](db, DBSessionTable, DIALECTMYSQL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	columns := []string{"id", "user_id", "token", "create"}
+	selectSession := "SELECT `session`.`id`, `session`.`user_id`, `session`.`token`, `session`.`create` FROM `session`"
+
+	mock.ExpectQuery(selectSession + " WHERE `session`.`id`=?").WithArgs(7).
+		WillReturnRows(dbnamestest.NewRows(columns...).AddRow(7, 184216, "abc", "2023-05-12 21:41:23"))
+	mock.ExpectQuery(selectSession + " WHERE `session`.`id`=?").WithArgs(8).WillReturnRows(dbnamestest.NewRows(columns...))
+	session, err := sessions.Get(ctx, 7)
+	if err != nil || session.Id != 7 || session.Token != "abc" {
+		t.Errorf("bad get %+v %v", session, err)
+	}
+	if _, err := sessions.Get(ctx, 8); !errors.Is(err, BDERRORUSERNOTFOUND) {
+		t.Errorf("bad missing get %v", err)
+	}
+	if _, err := sessions.Get(ctx, 7, 8); !errors.Is(err, BDERRORPARAM) {
+		t.Errorf("bad pk accepted %v", err)
+	}
+
+	mock.ExpectQuery(selectSession + " WHERE `session`.`user_id`=? ORDER BY `session`.`create` DESC, `session`.`id` ASC LIMIT 2 OFFSET 1").
+		WithArgs(184216).WillReturnRows(dbnamestest.NewRows(columns...).
+		AddRow(9, 184216, "b", "2023-05-12 21:41:24").AddRow(7, 184216, "a", "2023-05-12 21:41:23"))
+	found, err := sessions.Find(ctx, []Predicate{{Column: "user_id", Operation: EQUAL, Values: []interface{}{UserIdType(184216)}}},
+		&FindOptions{OrderBy: "-create,id", Limit: 2, Offset: 1})
+	if err != nil || len(found) != 2 || found[0].Id != 9 || found[1].Token != "a" {
+		t.Errorf("bad find %+v %v", found, err)
+	}
+	if _, err := sessions.Find(ctx, nil, &FindOptions{OrderBy: "first_begin"}); !errors.Is(err, BDERRORPARAM) {
+		t.Errorf("unknown order accepted %v", err)
+	}
+
+	mock.ExpectQuery("SELECT COUNT(*) FROM `session` WHERE `session`.`user_id`=?").WithArgs(184216).
+		WillReturnRows(dbnamestest.NewRows("COUNT(*)").AddRow(2))
+	mock.ExpectQuery("SELECT 1 FROM `session` WHERE `session`.`token`=? LIMIT 1").WithArgs("x").
+		WillReturnRows(dbnamestest.NewRows("1"))
+	userID := Predicate{Column: "user_id", Operation: EQUAL, Values: []interface{}{184216}}
+	if count, err := sessions.Count(ctx, userID); err != nil || count != 2 {
+		t.Errorf("bad count %d %v", count, err)
+	}
+	if exists, err := sessions.Exists(ctx, Predicate{Column: "token", Operation: EQUAL, Values: []interface{}{"x"}}); err != nil || exists {
+		t.Errorf("bad exists %v %v", exists, err)
+	}
+
+	// autoinc с нулевым значением не вставляется, id приходит из базы
+	mock.ExpectExec("INSERT INTO `session` (`user_id`, `token`, `create`) VALUES (?, ?, ?)").
+		WithArgs(184216, "new", dbnamestest.AnyArg()).WillReturnResult(10, 1)
+	created := DBSession{UserId: 184216, Token: "new"}
+	if err := sessions.Insert(ctx, &created); err != nil || created.Id != 10 {
+		t.Errorf("bad insert %+v %v", created, err)
+	}
+	mock.ExpectExec("UPDATE `session` SET `user_id` = ?, `token` = ?, `create` = ? WHERE `session`.`id`=?").
+		WithArgs(184216, "upd", dbnamestest.AnyArg(), 10).WillReturnResult(0, 1)
+	created.Token = "upd"
+	if affected, err := sessions.Update(ctx, &created); err != nil || affected != 1 {
+		t.Errorf("bad update %d %v", affected, err)
+	}
+	mock.ExpectExec("INSERT INTO `session` (`id`, `user_id`, `token`, `create`) VALUES (?, ?, ?, ?) ON DUPLICATE KEY UPDATE `user_id` = VALUES(`user_id`), `token` = VALUES(`token`), `create` = VALUES(`create`)").
+		WithArgs(10, 184216, "upd", dbnamestest.AnyArg()).WillReturnResult(10, 2)
+	if err := sessions.Upsert(ctx, &created); err != nil {
+		t.Error(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+
+	// внутри InTx запросы идут в транзакцию из контекста
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM `session` WHERE `session`.`id`=?").WithArgs(10).WillReturnResult(0, 1)
+	mock.ExpectCommit()
+	err = InTx(ctx, db, nil, func(ctx context.Context, tx *Tx) error {
+		affected, err := sessions.Delete(ctx, 10)
+		if affected != 1 {
+			t.Errorf("bad delete %d", affected)
+		}
+		return err
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+
+	pg, err := NewRepo[DBSession](db, DBSessionTable, DIALECTPOSTGRES)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock.ExpectQuery(`INSERT INTO "session" ("user_id", "token", "create") VALUES ($1, $2, $3) ON CONFLICT ("id") DO UPDATE SET "user_id" = EXCLUDED."user_id", "token" = EXCLUDED."token", "create" = EXCLUDED."create" RETURNING "id"`).
+		WithArgs(1, "pg", dbnamestest.AnyArg()).WillReturnRows(dbnamestest.NewRows("id").AddRow(11))
+	fresh := DBSession{UserId: 1, Token: "pg"}
+	if err := pg.Upsert(ctx, &fresh); err != nil || fresh.Id != 11 {
+		t.Errorf("bad postgres upsert %+v %v", fresh, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+
+	// строка уже есть и обновлять нечего: это не ошибка и id не затирается
+	links, err := NewRepo[DBSessionLink](db, "session_link", DIALECTMYSQL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock.ExpectExec("INSERT IGNORE INTO `session_link` (`user_id`) VALUES (?)").WithArgs(5).WillReturnResult(7, 0)
+	link := DBSessionLink{UserId: 5}
+	if err := links.Upsert(ctx, &link); err != nil || link.Id != 0 {
+		t.Errorf("bad ignored upsert %+v %v", link, err)
+	}
+	pgLinks, err := NewRepo[DBSessionLink](db, "session_link", DIALECTPOSTGRES)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock.ExpectQuery(`INSERT INTO "session_link" ("user_id") VALUES ($1) ON CONFLICT ("id", "user_id") DO NOTHING RETURNING "id"`).
+		WithArgs(5).WillReturnRows(dbnamestest.NewRows("id"))
+	if err := pgLinks.Upsert(ctx, &link); err != nil || link.Id != 0 {
+		t.Errorf("bad postgres do nothing %+v %v", link, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type DBSessionLink struct {
+	Id     uint64     `db:"id" dbddl:"pk,autoinc"`
+	UserId UserIdType `db:"user_id" dbddl:"pk"`
+}
@@ -0,0 +1,58 @@
+package dbnames
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"reflect"
+)
+
+/*
+функция выполняет запрос и отдает строки результата как итератор, каждая строка
+заполняется через FillByDBResult (или FillFromDBResult если T его реализует),
+выход из цикла через break закрывает *sql.Rows, ошибка запроса, чтения строки
+или rows.Err() приходит последним элементом с нулевым T. T должен быть структурой
+(не указателем на нее), иначе до запроса приходит ошибка с кодом BDERRORPARAM
+
+	for auth, err := range dbnames.Rows[DBAuth](ctx, db, query, args...) {
+		if err != nil {
+			return err
+		}
+		...
+	}
+*/
+func Rows[T any](ctx context.Context, q Querier, query string, args ...interface{}) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		if _, ok := any(&zero).(DBResultFiller); !ok && reflect.TypeOf(&zero).Elem().Kind() != reflect.Struct {
+			yield(zero, &Error{Code: BDERRORPARAM, Err: fmt.Errorf("rows of %T: need struct", zero)})
+			return
+		}
+		rows, err := q.QueryContext(ctx, query, args...)
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+		defer rows.Close()
+		// имена столбцов разбираются один раз на весь результат
+		res, err := New(rows)
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+		for res.Next() {
+			if err := res.Scan(); err != nil {
+				yield(zero, err)
+				return
+			}
+			var row T
+			FillByDBResult(res, &row)
+			if !yield(row, nil) {
+				return
+			}
+		}
+		if err := rows.Err(); err != nil {
+			yield(zero, err)
+		}
+	}
+}
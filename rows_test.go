@@ -0,0 +1,102 @@
+package dbnames
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/denisbdn/dbnames/dbnamestest"
+)
+
+func TestRows(t *testing.T) {
+	db, mock, err := dbnamestest.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	ctx := context.Background()
+	query := "SELECT `user_id`, `auth_type`, `create`, `data` FROM `auth` WHERE `auth_type` = ?"
+	authRows := func() *dbnamestest.Rows {
+		return dbnamestest.NewRows("user_id", "auth_type", "create", "data").
+			AddRow(1, 0, "2023-05-12 21:41:23", "a").
+			AddRow(2, 0, "2023-05-12 21:41:24", nil).
+			AddRow(3, 0, "2023-05-12 21:41:25", "c")
+	}
+
+	mock.ExpectQuery(query).WithArgs(0).WillReturnRows(authRows())
+	ids := make([]UserIdType, 0)
+	for auth, err := range Rows[DBAuth](ctx, db, query, 0) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, auth.UserId)
+	}
+	if len(ids) != 3 || ids[2] != 3 {
+		t.Errorf("bad rows %v", ids)
+	}
+
+	// break закрывает rows, иначе единственное соединение осталось бы занято
+	mock.ExpectQuery(query).WithArgs(0).WillReturnRows(authRows())
+	mock.ExpectQuery("SELECT 1").WillReturnRows(dbnamestest.NewRows("1").AddRow(1))
+	for auth, err := range Rows[DBAuth](ctx, db, query, 0) {
+		if err != nil || auth.UserId != 1 {
+			t.Fatal(auth, err)
+		}
+		break
+	}
+	timeout, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	var one int
+	if err := db.QueryRowContext(timeout, "SELECT 1").Scan(&one); err != nil {
+		t.Fatal(err)
+	}
+
+	// ошибка запроса приходит единственным элементом
+	failed := errors.New("failed")
+	mock.ExpectQuery(query).WillReturnError(failed)
+	count := 0
+	for _, err := range Rows[DBSession](ctx, db, query, 0) {
+		count++
+		if !errors.Is(err, failed) {
+			t.Errorf("bad error %v", err)
+		}
+	}
+	if count != 1 {
+		t.Errorf("bad count %d", count)
+	}
+
+	// структура без FillFromDBResult заполняется рефлексией
+	mock.ExpectQuery("SELECT * FROM `session`").WillReturnRows(
+		dbnamestest.NewRows("id", "user_id", "token", "create").AddRow(7, 184216, "abc", "2023-05-12 21:41:23"))
+	for session, err := range Rows[DBSession](ctx, db, "SELECT * FROM `session`") {
+		if err != nil || session.Id != 7 || session.Token != "abc" {
+			t.Errorf("bad session %+v %v", session, err)
+		}
+	}
+
+	// сгенерированный FillFromDBResult пропускает неразобранное поле, как и рефлексия
+	mock.ExpectQuery(query).WithArgs(0).WillReturnRows(
+		dbnamestest.NewRows("user_id", "auth_type", "create", "data").AddRow("x", 0, "2023-05-12 21:41:23", "a"))
+	for auth, err := range Rows[DBAuth](ctx, db, query, 0) {
+		if err != nil || auth.UserId != 0 || auth.Data != "a" {
+			t.Errorf("bad auth %+v %v", auth, err)
+		}
+	}
+
+	// не структура это ошибка параметра, запрос не выполняется
+	for _, err := range Rows[*DBAuth](ctx, db, query, 0) {
+		if !errors.Is(err, BDERRORPARAM) {
+			t.Errorf("bad pointer error %v", err)
+		}
+	}
+	for _, err := range Rows[int](ctx, db, "SELECT 1") {
+		if !errors.Is(err, BDERRORPARAM) {
+			t.Errorf("bad int error %v", err)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
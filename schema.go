@@ -0,0 +1,240 @@
+package dbnames
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// эта часть сверяет структуру с реальной таблицей в базе или с ее дампом
+
+type SchemaIssueKind int
+
+const (
+	ISSUEMISSINGCOLUMN SchemaIssueKind = iota // у поля структуры нет столбца
+	ISSUEEXTRACOLUMN                          // у столбца нет поля в структуре
+	ISSUENULLABLE                             // столбец NULL, а поле не указатель
+	ISSUETYPE                                 // тип столбца не помещается в поле
+)
+
+func (kind SchemaIssueKind) String() string {
+	switch kind {
+	case ISSUEMISSINGCOLUMN:
+		return "missing column"
+	case ISSUEEXTRACOLUMN:
+		return "extra column"
+	case ISSUENULLABLE:
+		return "nullable column"
+	case ISSUETYPE:
+		return "incompatible type"
+	}
+	return fmt.Sprintf("issue(%d)", int(kind))
+}
+
+type SchemaIssue struct {
+	Column  string
+	Kind    SchemaIssueKind
+	Message string
+}
+
+func (issue SchemaIssue) String() string {
+	return fmt.Sprintf("%s %s: %s", issue.Kind, issue.Column, issue.Message)
+}
+
+/*
+строка из INFORMATION_SCHEMA.COLUMNS
+*/
+type informationColumn struct {
+	Name     string  `db:"COLUMN_NAME"`
+	Type     string  `db:"COLUMN_TYPE"`
+	Nullable string  `db:"IS_NULLABLE"`
+	Default  *string `db:"COLUMN_DEFAULT"`
+	Key      string  `db:"COLUMN_KEY"`
+	Extra    string  `db:"EXTRA"`
+}
+
+/*
+функция читает описание столбцов таблицы table текущей базы из INFORMATION_SCHEMA.COLUMNS,
+индексы кроме первичного ключа не читаются (для них см ParseCreateTable)
+*/
+func LoadTable(ctx context.Context, db Querier, table string) (*Table, error) {
+	fields := BuildFields("", informationColumn{})
+	query := fmt.Sprintf("SELECT %s FROM `INFORMATION_SCHEMA`.`COLUMNS` WHERE `TABLE_SCHEMA` = DATABASE() AND `TABLE_NAME` = ? ORDER BY `ORDINAL_POSITION`;",
+		strings.Join(fields, ", "))
+	rows, err := db.QueryContext(ctx, query, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res, err := New(rows)
+	if err != nil {
+		return nil, err
+	}
+	loaded := &Table{Name: table}
+	for res.Next() {
+		if err := res.Scan(); err != nil {
+			return nil, err
+		}
+		info := informationColumn{}
+		FillByDBResult(res, &info)
+		loaded.Columns = append(loaded.Columns, Column{
+			Name:          info.Name,
+			Type:          info.Type,
+			Null:          info.Nullable == "YES",
+			Default:       info.Default,
+			AutoIncrement: strings.Contains(strings.ToLower(info.Extra), "auto_increment"),
+		})
+		if info.Key == "PRI" {
+			loaded.PrimaryKey = append(loaded.PrimaryKey, info.Name)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(loaded.Columns) == 0 {
+		return nil, fmt.Errorf("table %s not found", table)
+	}
+	return loaded, nil
+}
+
+/*
+функция сравнивает структуру model (см TableFromStruct) с описанием таблицы table
+(из LoadTable или ParseCreateTable) и выдает все расхождения: поля без столбцов,
+столбцы без полей, NULL столбцы в поля не указатели (кроме MYSQLDATETIME у которого
+нулевое значение и есть NULL) и типы которые не помещаются в поле, например BIGINT в uint32
+*/
+func CheckStruct(table *Table, model interface{}) []SchemaIssue {
+	issues := make([]SchemaIssue, 0)
+	modelType := reflect.TypeOf(model)
+	if modelType.Kind() == reflect.Pointer {
+		modelType = modelType.Elem()
+	}
+	used := make(map[string]bool)
+	for i := 0; i < modelType.NumField(); i++ {
+		field := modelType.Field(i)
+		dbFieldName, find := field.Tag.Lookup("db")
+		if !find {
+			continue
+		}
+		used[dbFieldName] = true
+		column := table.Column(dbFieldName)
+		if column == nil {
+			issues = append(issues, SchemaIssue{Column: dbFieldName, Kind: ISSUEMISSINGCOLUMN,
+				Message: fmt.Sprintf("field %s has no column in %s", field.Name, table.Name)})
+			continue
+		}
+		fieldType := field.Type
+		pointer := fieldType.Kind() == reflect.Pointer
+		if pointer {
+			fieldType = fieldType.Elem()
+		}
+		if column.Null && !pointer && fieldType != mysqlDateTimeType {
+			issues = append(issues, SchemaIssue{Column: dbFieldName, Kind: ISSUENULLABLE,
+				Message: fmt.Sprintf("NULL can't be stored in field %s %s", field.Name, field.Type)})
+		}
+		if !typeFits(column.Type, fieldType) {
+			issues = append(issues, SchemaIssue{Column: dbFieldName, Kind: ISSUETYPE,
+				Message: fmt.Sprintf("%s can't be stored in field %s %s", column.Type, field.Name, field.Type)})
+		}
+	}
+	for _, column := range table.Columns {
+		if !used[column.Name] {
+			issues = append(issues, SchemaIssue{Column: column.Name, Kind: ISSUEEXTRACOLUMN,
+				Message: fmt.Sprintf("column of %s has no field in %s", table.Name, modelType)})
+		}
+	}
+	return issues
+}
+
+/*
+функция для старта сервиса: читает таблицу из базы и сверяет со структурой,
+лишние столбцы в таблице ошибкой не считаются (структура может читать не все)
+*/
+func VerifyStruct(ctx context.Context, db Querier, table string, model interface{}) error {
+	table = resolveTable(table, model)
+	loaded, err := LoadTable(ctx, db, table)
+	if err != nil {
+		return err
+	}
+	problems := make([]string, 0)
+	for _, issue := range CheckStruct(loaded, model) {
+		if issue.Kind != ISSUEEXTRACOLUMN {
+			problems = append(problems, issue.String())
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("table %s: %s", table, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+/*
+внутрення функция пакета, разбирает тип столбца: int(10) unsigned -> int, true
+*/
+func parseColumnType(columnType string) (string, bool) {
+	base := strings.ToLower(strings.TrimSpace(columnType))
+	unsigned := strings.Contains(base, "unsigned")
+	if i := strings.IndexAny(base, "( "); i >= 0 {
+		base = base[:i]
+	}
+	return base, unsigned
+}
+
+// число бит целых типов MySQL
+var integerBits = map[string]int{
+	"tinyint":   8,
+	"smallint":  16,
+	"mediumint": 24,
+	"int":       32,
+	"integer":   32,
+	"bigint":    64,
+}
+
+/*
+внутрення функция пакета, проверяет что значение столбца columnType можно
+без потерь положить в поле типа fieldType
+*/
+func typeFits(columnType string, fieldType reflect.Type) bool {
+	// свои типы разбирают строку сами, о них судить не можем
+	if fieldType != mysqlDateTimeType && fieldType != timeType &&
+		reflect.PointerTo(fieldType).Implements(reflect.TypeOf((*FromStringInteface)(nil)).Elem()) {
+		return true
+	}
+	kind := fieldType.Kind()
+	if kind == reflect.String {
+		return true
+	}
+	base, unsigned := parseColumnType(columnType)
+	if bits, ok := integerBits[base]; ok {
+		if kind == reflect.Bool {
+			return base == "tinyint"
+		}
+		switch kindClass(kind) {
+		case reflect.Int:
+			fieldBits := fieldType.Bits()
+			if unsigned {
+				return fieldBits > bits
+			}
+			return fieldBits >= bits
+		case reflect.Uint:
+			return unsigned && fieldType.Bits() >= bits
+		case reflect.Float64:
+			return true
+		}
+		return false
+	}
+	switch base {
+	case "decimal", "numeric", "float", "double", "real":
+		return kindClass(kind) == reflect.Float64
+	case "bit", "year":
+		return kindClass(kind) == reflect.Int || kindClass(kind) == reflect.Uint
+	case "date", "datetime", "timestamp", "time":
+		return fieldType == mysqlDateTimeType || fieldType == timeType
+	case "char", "varchar", "tinytext", "text", "mediumtext", "longtext", "enum", "set", "json",
+		"binary", "varbinary", "tinyblob", "blob", "mediumblob", "longblob":
+		return fieldType == bytesType || (kind == reflect.Slice && fieldType.Elem().Kind() == reflect.Uint8)
+	}
+	// неизвестный тип не проверяем
+	return true
+}
@@ -0,0 +1,206 @@
+package dbnames
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/denisbdn/dbnames/dbnamestest"
+)
+
+const authCreateTable = "CREATE TABLE `auth` (\n" +
+	"  `user_id` int(10) unsigned NOT NULL,\n" +
+	"  `auth_type` tinyint(4) NOT NULL,\n" +
+	"  `create` datetime NOT NULL,\n" +
+	"  `data` varchar(255) DEFAULT NULL\n" +
+	") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;"
+
+const sessionDump = "/*!40101 SET @saved_cs_client     = @@character_set_client */;\n" +
+	"DROP TABLE IF EXISTS `session`;\n" +
+	"CREATE TABLE `session` (\n" +
+	"  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,\n" +
+	"  `user_id` int(10) unsigned NOT NULL COMMENT 'owner, see `auth`',\n" +
+	"  `token` varchar(64) CHARACTER SET ascii NOT NULL,\n" +
+	"  `state` enum('new','used') NOT NULL DEFAULT 'new',\n" +
+	"  `expire` datetime DEFAULT NULL ON UPDATE CURRENT_TIMESTAMP,\n" +
+	"  `score` bigint(20) DEFAULT NULL,\n" +
+	"  PRIMARY KEY (`id`),\n" +
+	"  UNIQUE KEY `uniq_token` (`token`),\n" +
+	"  KEY `idx_user_state` (`user_id`,`state`(2)),\n" +
+	"  CONSTRAINT `fk_user` FOREIGN KEY (`user_id`) REFERENCES `auth` (`user_id`)\n" +
+	") ENGINE=InnoDB AUTO_INCREMENT=42 DEFAULT CHARSET=utf8mb4;\n" +
+	"INSERT INTO `session` VALUES (1,184216,'abc','new',NULL,NULL);\n"
+
+func TestParseCreateTable(t *testing.T) {
+	auth, err := ParseCreateTable(authCreateTable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if auth.Name != "auth" || len(auth.Columns) != 4 || auth.Engine != "InnoDB" || auth.Charset != "utf8mb4" || auth.Collate != "utf8mb4_bin" {
+		t.Errorf("bad table %+v", auth)
+	}
+	if c := auth.Column("user_id"); c == nil || c.Type != "int(10) unsigned" || c.Null {
+		t.Errorf("bad user_id %+v", c)
+	}
+	if c := auth.Column("data"); c == nil || !c.Null || c.Default == nil || *c.Default != "NULL" {
+		t.Errorf("bad data %+v", c)
+	}
+
+	tables, err := ParseCreateTables(sessionDump)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tables) != 1 {
+		t.Fatalf("bad tables count %d", len(tables))
+	}
+	session := tables[0]
+	if len(session.Columns) != 6 || len(session.PrimaryKey) != 1 || session.PrimaryKey[0] != "id" {
+		t.Errorf("bad session %+v", session)
+	}
+	if c := session.Column("id"); c == nil || !c.AutoIncrement || c.Type != "bigint(20) unsigned" {
+		t.Errorf("bad id %+v", c)
+	}
+	if c := session.Column("state"); c == nil || c.Type != "enum('new','used')" || *c.Default != "'new'" {
+		t.Errorf("bad state %+v", c)
+	}
+	if len(session.Indexes) != 2 || !session.Indexes[0].Unique || len(session.Indexes[1].Columns) != 2 || session.Indexes[1].Columns[1] != "state" {
+		t.Errorf("bad indexes %+v", session.Indexes)
+	}
+
+	// выражения по умолчанию в скобках не обрывают разбор остальных столбцов
+	exprDefaults, err := ParseCreateTable("CREATE TABLE `token` (\n" +
+		"  `id` binary(16) NOT NULL DEFAULT (uuid_to_bin(uuid())),\n" +
+		"  `created` datetime(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3) ON UPDATE CURRENT_TIMESTAMP(3),\n" +
+		"  `score` int NOT NULL DEFAULT -1,\n" +
+		"  PRIMARY KEY (`id`)\n" +
+		") ENGINE=InnoDB;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exprDefaults.Columns) != 3 || len(exprDefaults.PrimaryKey) != 1 || exprDefaults.Engine != "InnoDB" {
+		t.Fatalf("bad table %+v", exprDefaults)
+	}
+	if c := exprDefaults.Column("id"); *c.Default != "(uuid_to_bin(uuid()))" {
+		t.Errorf("bad id default %s", *c.Default)
+	}
+	if c := exprDefaults.Column("created"); *c.Default != "CURRENT_TIMESTAMP(3)" {
+		t.Errorf("bad created default %s", *c.Default)
+	}
+	if c := exprDefaults.Column("score"); *c.Default != "-1" {
+		t.Errorf("bad score default %s", *c.Default)
+	}
+	// лишняя скобка это ошибка, а не потерянные столбцы
+	if _, err := ParseCreateTable("CREATE TABLE `t` (`a` int, `b` int)), `c` int) ENGINE=InnoDB;"); err == nil {
+		t.Errorf("unbalanced definitions accepted")
+	}
+
+	// то что генерируем сами тоже должно разбираться
+	generated, err := BuildCreateTable(DBAuthTable, DBAuth{}, DIALECTMYSQL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseCreateTable(generated); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCheckStruct(t *testing.T) {
+	auth, err := ParseCreateTable(authCreateTable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// data может быть NULL, а в структуре строка
+	issues := CheckStruct(auth, DBAuth{})
+	if len(issues) != 1 || issues[0].Kind != ISSUENULLABLE || issues[0].Column != "data" {
+		t.Errorf("bad issues %v", issues)
+	}
+
+	type DBAuthNull struct {
+		UserId UserIdType    `db:"user_id"`
+		Type   AuthType      `db:"auth_type"`
+		Create MYSQLDATETIME `db:"create"`
+		Data   *string       `db:"data"`
+	}
+	if issues := CheckStruct(auth, DBAuthNull{}); len(issues) != 0 {
+		t.Errorf("bad issues %v", issues)
+	}
+
+	session, err := ParseCreateTables(sessionDump)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type DBSession struct {
+		Id     uint32   `db:"id"`
+		UserId int32    `db:"user_id"`
+		Token  []byte   `db:"token"`
+		Score  *int64   `db:"score"`
+		Expire *string  `db:"expire"`
+		Lost   string   `db:"lost"`
+		State  AuthType `db:"state"`
+	}
+	issues = CheckStruct(session[0], DBSession{})
+	kinds := make(map[string]SchemaIssueKind)
+	for _, issue := range issues {
+		kinds[issue.Column] = issue.Kind
+		t.Log(issue)
+	}
+	// bigint в uint32, unsigned int в int32, enum в число, нет столбца lost
+	if len(issues) != 4 || kinds["id"] != ISSUETYPE || kinds["user_id"] != ISSUETYPE || kinds["state"] != ISSUETYPE || kinds["lost"] != ISSUEMISSINGCOLUMN {
+		t.Errorf("bad issues %v", issues)
+	}
+}
+
+func TestFillPointers(t *testing.T) {
+	type DBAuthNull struct {
+		UserId *UserIdType    `db:"user_id"`
+		Create *MYSQLDATETIME `db:"create"`
+		Data   *string        `db:"data"`
+	}
+	res := &DBResult{
+		values: []sql.RawBytes{[]byte("184216"), []byte("2023-05-12 21:41:23"), nil},
+		names:  map[string]int{"user_id": 0, "create": 1, "data": 2},
+	}
+	old := "old"
+	data := DBAuthNull{Data: &old}
+	if count := FillByDBResult(res, &data); count != 2 {
+		t.Errorf("bad count %d", count)
+	}
+	if data.UserId == nil || *data.UserId != 184216 || data.Create == nil || data.Create.ToString() != "'2023-05-12 21:41:23'" || data.Data != nil {
+		t.Errorf("bad data %v", data)
+	}
+}
+
+func TestLoadTable(t *testing.T) {
+	db, mock, err := dbnamestest.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	mock.ExpectQueryRegexp("FROM `INFORMATION_SCHEMA`.`COLUMNS`").WithArgs(DBAuthTable).WillReturnRows(
+		dbnamestest.NewRows("COLUMN_NAME", "COLUMN_TYPE", "IS_NULLABLE", "COLUMN_DEFAULT", "COLUMN_KEY", "EXTRA").
+			AddRow("user_id", "int(10) unsigned", "NO", nil, "PRI", "auto_increment").
+			AddRow("auth_type", "tinyint(4)", "NO", "0", "", "").
+			AddRow("create", "datetime", "NO", nil, "", "").
+			AddRow("data", "varchar(255)", "YES", nil, "", ""))
+
+	if err := VerifyStruct(context.Background(), db, DBAuthTable, DBAuth{}); err == nil {
+		t.Errorf("nullable data not reported")
+	} else {
+		t.Log(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+
+	mock.ExpectQueryRegexp("FROM `INFORMATION_SCHEMA`.`COLUMNS`").WithArgs(DBAuthTable).WillReturnRows(
+		dbnamestest.NewRows("COLUMN_NAME", "COLUMN_TYPE", "IS_NULLABLE", "COLUMN_DEFAULT", "COLUMN_KEY", "EXTRA").
+			AddRow("user_id", "int(10) unsigned", "NO", nil, "PRI", "auto_increment").
+			AddRow("auth_type", "tinyint(4)", "NO", "0", "", ""))
+	table, err := LoadTable(context.Background(), db, DBAuthTable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(table.Columns) != 2 || !table.Columns[0].AutoIncrement || table.Columns[0].Default != nil || *table.Columns[1].Default != "0" || table.PrimaryKey[0] != "user_id" {
+		t.Errorf("bad table %+v", table)
+	}
+}
@@ -0,0 +1,477 @@
+package dbnames
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+// эта часть файла распределяет строки по нескольким базам (шардам) по ключу
+
+/*
+стратегия выбора шарда по ключу, ключ уже приведен к uint64 (см ShardKey),
+результат номер шарда с нуля или -1 если ключу шард не назначен
+*/
+type ShardStrategy interface {
+	Shard(key uint64) int
+}
+
+/*
+шард это остаток от деления ключа на число шардов
+*/
+type Modulo int
+
+func (m Modulo) Shard(key uint64) int {
+	if m <= 0 {
+		return -1
+	}
+	return int(key % uint64(m))
+}
+
+/*
+консистентное хеширование: каждый шард занимает replicas точек на кольце,
+при добавлении шарда переезжает только часть ключей
+*/
+type ConsistentHash struct {
+	points []uint64
+	shards []int
+}
+
+const DefaultHashReplicas = 160
+
+func NewConsistentHash(shards int, replicas int) *ConsistentHash {
+	if replicas <= 0 {
+		replicas = DefaultHashReplicas
+	}
+	type point struct {
+		hash  uint64
+		shard int
+	}
+	points := make([]point, 0, shards*replicas)
+	for shard := 0; shard < shards; shard++ {
+		for replica := 0; replica < replicas; replica++ {
+			points = append(points, point{hash: mix64(hashString(fmt.Sprintf("shard-%d-%d", shard, replica))), shard: shard})
+		}
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].hash < points[j].hash })
+	ring := &ConsistentHash{points: make([]uint64, len(points)), shards: make([]int, len(points))}
+	for i, p := range points {
+		ring.points[i] = p.hash
+		ring.shards[i] = p.shard
+	}
+	return ring
+}
+
+func (c *ConsistentHash) Shard(key uint64) int {
+	if len(c.points) == 0 {
+		return -1
+	}
+	hash := mix64(key)
+	i := sort.Search(len(c.points), func(i int) bool { return c.points[i] >= hash })
+	if i == len(c.points) {
+		i = 0
+	}
+	return c.shards[i]
+}
+
+/*
+диапазон ключей [From, следующий From) живет на шарде Shard
+*/
+type ShardRange struct {
+	From  uint64
+	Shard int
+}
+
+/*
+таблица диапазонов, ключи меньше первого From шарда не имеют
+*/
+type RangeTable []ShardRange
+
+func NewRangeTable(ranges ...ShardRange) RangeTable {
+	table := append(RangeTable(nil), ranges...)
+	sort.Slice(table, func(i, j int) bool { return table[i].From < table[j].From })
+	return table
+}
+
+func (t RangeTable) Shard(key uint64) int {
+	i := sort.Search(len(t), func(i int) bool { return t[i].From > key })
+	if i == 0 {
+		return -1
+	}
+	return t[i-1].Shard
+}
+
+/*
+внутрення функция пакета, перемешивание битов (финализатор splitmix64): у fnv похожие
+входы дают близкие значения, а на кольце нужны равномерно разбросанные точки
+*/
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+func hashString(str string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(str))
+	return h.Sum64()
+}
+
+/*
+функция приводит ключ шардирования к uint64: целые как есть (отрицательные ошибка),
+строки через хеш fnv
+*/
+func ShardKey(key interface{}) (uint64, error) {
+	value := reflect.ValueOf(key)
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if value.Int() < 0 {
+			return 0, fmt.Errorf("negative shard key %d", value.Int())
+		}
+		return uint64(value.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return value.Uint(), nil
+	case reflect.String:
+		return hashString(value.String()), nil
+	}
+	return 0, fmt.Errorf("type %T can't be shard key", key)
+}
+
+/*
+маршрутизатор по шардам: ключ (поле с тегом shardkey или значение) через стратегию
+выбирает одну из баз
+
+	router, err := dbnames.NewRouter(dbnames.Modulo(2), db0, db1)
+	db, err := router.For(&auth)
+	err = sessions.With(db).Insert(ctx, &auth)
+*/
+type Router struct {
+	shards   []*sql.DB
+	strategy ShardStrategy
+}
+
+/*
+функция создает роутер, ошибка если нет шардов или стратегии
+*/
+func NewRouter(strategy ShardStrategy, shards ...*sql.DB) (*Router, error) {
+	if len(shards) == 0 {
+		return nil, fmt.Errorf("no shards")
+	}
+	if value := reflect.ValueOf(strategy); !value.IsValid() || (value.Kind() == reflect.Pointer && value.IsNil()) {
+		return nil, fmt.Errorf("no shard strategy")
+	}
+	return &Router{shards: shards, strategy: strategy}, nil
+}
+
+// Shards число шардов
+func (r *Router) Shards() int {
+	return len(r.shards)
+}
+
+// Shard база шарда с номером n, номер вне [0, Shards()) это паника как при индексе слайса
+func (r *Router) Shard(n int) *sql.DB {
+	return r.shards[n]
+}
+
+/*
+номер шарда для значения ключа
+*/
+func (r *Router) ShardFor(key interface{}) (int, error) {
+	k, err := ShardKey(key)
+	if err != nil {
+		return -1, err
+	}
+	shard := r.strategy.Shard(k)
+	if shard < 0 || shard >= len(r.shards) {
+		return -1, fmt.Errorf("no shard for key %v", key)
+	}
+	return shard, nil
+}
+
+/*
+база для значения ключа
+*/
+func (r *Router) DB(key interface{}) (*sql.DB, error) {
+	shard, err := r.ShardFor(key)
+	if err != nil {
+		return nil, err
+	}
+	return r.shards[shard], nil
+}
+
+/*
+база для строки row (структура или указатель на нее) по полю с тегом shardkey
+*/
+func (r *Router) For(row interface{}) (*sql.DB, error) {
+	value := reflect.Indirect(reflect.ValueOf(row))
+	if value.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("need struct, got %T", row)
+	}
+	index, ok := shardKeyField(value.Type())
+	if !ok {
+		return nil, fmt.Errorf("%s has no shardkey field", value.Type())
+	}
+	return r.DB(value.Field(index).Interface())
+}
+
+/*
+внутрення функция пакета, номер поля с тегом shardkey (значение тега не важно)
+*/
+func shardKeyField(t reflect.Type) (int, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		if _, ok := t.Field(i).Tag.Lookup("shardkey"); ok {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+/*
+функция выполняет fn для шардов shards (все если nil) параллельно, первая ошибка
+отменяет контекст остальных и возвращается с номером шарда, номер вне роутера
+или повтор номера это ошибка, fn тогда не вызывается ни разу
+*/
+func (r *Router) Each(ctx context.Context, shards []int, fn func(ctx context.Context, shard int, db *sql.DB) error) error {
+	if shards == nil {
+		shards = make([]int, len(r.shards))
+		for i := range shards {
+			shards[i] = i
+		}
+	}
+	used := make([]bool, len(r.shards))
+	for _, shard := range shards {
+		if shard < 0 || shard >= len(r.shards) {
+			return fmt.Errorf("no shard %d, router has %d", shard, len(r.shards))
+		}
+		if used[shard] {
+			return fmt.Errorf("shard %d listed twice", shard)
+		}
+		used[shard] = true
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	for _, shard := range shards {
+		wg.Add(1)
+		go func(shard int) {
+			defer wg.Done()
+			if err := fn(ctx, shard, r.shards[shard]); err != nil {
+				once.Do(func() {
+					firstErr = fmt.Errorf("shard %d: %w", shard, err)
+					cancel()
+				})
+			}
+		}(shard)
+	}
+	wg.Wait()
+	return firstErr
+}
+
+/*
+внутрення функция пакета, шарды для условий: если есть EQUAL или IN на столбец
+с тегом shardkey, то только шарды этих значений, иначе nil (все шарды)
+*/
+func (r *Router) shardsFor(model reflect.Type, where []Predicate) ([]int, error) {
+	index, ok := shardKeyField(model)
+	if !ok {
+		return nil, nil
+	}
+	column, ok := model.Field(index).Tag.Lookup("db")
+	if !ok {
+		return nil, nil
+	}
+	for _, pred := range where {
+		if pred.Column != column || (pred.Operation != EQUAL && pred.Operation != IN) {
+			continue
+		}
+		used := make(map[int]bool)
+		shards := make([]int, 0, len(pred.Values))
+		for _, value := range pred.Values {
+			shard, err := r.ShardFor(value)
+			if err != nil {
+				return nil, err
+			}
+			if !used[shard] {
+				used[shard] = true
+				shards = append(shards, shard)
+			}
+		}
+		sort.Ints(shards)
+		return shards, nil
+	}
+	return nil, nil
+}
+
+/*
+функция выполняет repo.Find на шардах параллельно и сливает результат: сортирует по
+opts.OrderBy и применяет Limit и Offset к общему результату (каждый шард отдает первые
+Offset+Limit строк), если в where есть EQUAL или IN на поле shardkey то запрос идет
+только на нужные шарды, без OrderBy строки идут в порядке шардов.
+Сортировать можно по числам, строкам, bool и времени (и указателям на них), для других
+типов (sql.NullString, []byte, decimal) ошибка BDERRORPARAM, как и если opts.Fields
+не содержит столбцы OrderBy. Строки сравниваются побайтово,
+как при бинарной collation (utf8mb4_bin), с _ci collation порядок и граница LIMIT
+могут отличаться от того что вернул бы один сервер
+*/
+func FanOut[T any](ctx context.Context, router *Router, repo *Repo[T], where []Predicate, opts *FindOptions) ([]T, error) {
+	if opts == nil {
+		opts = &FindOptions{}
+	}
+	var model T
+	sortFields, err := parseSortFields(model, []string{opts.OrderBy})
+	if err != nil {
+		return nil, &Error{Code: BDERRORPARAM, Err: err}
+	}
+	if opts.Limit < 0 || opts.Offset < 0 {
+		return nil, &Error{Code: BDERRORPARAM, Err: fmt.Errorf("negative limit %d or offset %d", opts.Limit, opts.Offset)}
+	}
+	modelType := reflect.TypeOf(model)
+	indexes := make([]int, len(sortFields))
+	for i, field := range sortFields {
+		indexes[i] = fieldIndexByColumn(modelType, field.name)
+		if indexes[i] < 0 || !sortableType(modelType.Field(indexes[i]).Type) {
+			return nil, &Error{Code: BDERRORPARAM, Err: fmt.Errorf("can't merge shards ordered by %s", field.name)}
+		}
+		if len(opts.Fields) > 0 && !containsString(opts.Fields, field.name) {
+			// без столбца в выборке поле в строках нулевое и слияние его не видит
+			return nil, &Error{Code: BDERRORPARAM, Err: fmt.Errorf("can't merge shards ordered by %s: not in fields", field.name)}
+		}
+	}
+	shards, err := router.shardsFor(modelType, where)
+	if err != nil {
+		return nil, &Error{Code: BDERRORPARAM, Err: err}
+	}
+	shardOpts := *opts
+	shardOpts.Offset = 0
+	if opts.Limit > 0 {
+		shardOpts.Limit = opts.Limit + opts.Offset
+	}
+	results := make([][]T, len(router.shards))
+	err = router.Each(ctx, shards, func(ctx context.Context, shard int, db *sql.DB) error {
+		// транзакция InTx из контекста живет на одной базе, шарды ее не видят
+		ctx = context.WithValue(ctx, txContextKey{}, (*Tx)(nil))
+		rows, err := repo.With(db).Find(ctx, where, &shardOpts)
+		results[shard] = rows
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	merged := make([]T, 0)
+	for _, rows := range results {
+		merged = append(merged, rows...)
+	}
+	if len(sortFields) > 0 {
+		sort.SliceStable(merged, func(i, j int) bool {
+			a := reflect.ValueOf(&merged[i]).Elem()
+			b := reflect.ValueOf(&merged[j]).Elem()
+			for k, field := range sortFields {
+				c := compareValues(a.Field(indexes[k]), b.Field(indexes[k]))
+				if c == 0 {
+					continue
+				}
+				if field.desc {
+					return c > 0
+				}
+				return c < 0
+			}
+			return false
+		})
+	}
+	if opts.Offset >= len(merged) {
+		return merged[:0], nil
+	}
+	merged = merged[opts.Offset:]
+	if opts.Limit > 0 && opts.Limit < len(merged) {
+		merged = merged[:opts.Limit]
+	}
+	return merged, nil
+}
+
+func fieldIndexByColumn(t reflect.Type, column string) int {
+	for i := 0; i < t.NumField(); i++ {
+		if name, ok := t.Field(i).Tag.Lookup("db"); ok && name == column {
+			return i
+		}
+	}
+	return -1
+}
+
+/*
+внутрення функция пакета, true если compareValues умеет сравнивать значения типа t
+*/
+func sortableType(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.String, reflect.Bool:
+		return true
+	}
+	return t.ConvertibleTo(timeType)
+}
+
+/*
+внутрення функция пакета, сравнение значений полей для слияния как в ORDER BY:
+NULL (nil указатель) меньше любого значения, время (и MYSQLDATETIME) по времени
+*/
+func compareValues(a reflect.Value, b reflect.Value) int {
+	if a.Kind() == reflect.Pointer {
+		switch {
+		case a.IsNil() && b.IsNil():
+			return 0
+		case a.IsNil():
+			return -1
+		case b.IsNil():
+			return 1
+		}
+		return compareValues(a.Elem(), b.Elem())
+	}
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return compareOrdered(a.Int(), b.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return compareOrdered(a.Uint(), b.Uint())
+	case reflect.Float32, reflect.Float64:
+		return compareOrdered(a.Float(), b.Float())
+	case reflect.String:
+		return compareOrdered(a.String(), b.String())
+	case reflect.Bool:
+		return compareOrdered(boolOrder(a.Bool()), boolOrder(b.Bool()))
+	}
+	if a.Type().ConvertibleTo(timeType) {
+		return a.Convert(timeType).Interface().(time.Time).Compare(b.Convert(timeType).Interface().(time.Time))
+	}
+	return 0
+}
+
+func boolOrder(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func compareOrdered[V int64 | uint64 | float64 | string](a V, b V) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,174 @@
+package dbnames
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/denisbdn/dbnames/dbnamestest"
+)
+
+type DBEvent struct {
+	Id     uint64        `db:"id" dbddl:"pk"`
+	UserId uint32        `db:"user_id" shardkey:""`
+	Create MYSQLDATETIME `db:"create"`
+}
+
+type DBEventNote struct {
+	Id     uint64         `db:"id" dbddl:"pk"`
+	UserId uint32         `db:"user_id" shardkey:""`
+	Note   sql.NullString `db:"note"`
+}
+
+func TestShardStrategies(t *testing.T) {
+	if Modulo(3).Shard(184216) != 184216%3 || Modulo(0).Shard(1) != -1 {
+		t.Errorf("bad modulo")
+	}
+	ranges := NewRangeTable(ShardRange{From: 1000, Shard: 1}, ShardRange{From: 1, Shard: 0})
+	if ranges.Shard(0) != -1 || ranges.Shard(1) != 0 || ranges.Shard(999) != 0 || ranges.Shard(1000) != 1 || ranges.Shard(1<<40) != 1 {
+		t.Errorf("bad ranges %v", ranges)
+	}
+
+	three := NewConsistentHash(3, 0)
+	four := NewConsistentHash(4, 0)
+	counts := make([]int, 3)
+	moved := 0
+	const keys = 30000
+	for key := uint64(0); key < keys; key++ {
+		shard := three.Shard(key)
+		counts[shard]++
+		if key < 10 && shard != NewConsistentHash(3, 0).Shard(key) {
+			t.Errorf("ring is not deterministic")
+		}
+		if four.Shard(key) != shard {
+			moved++
+		}
+	}
+	for shard, count := range counts {
+		if count < keys/6 {
+			t.Errorf("shard %d got only %d keys", shard, count)
+		}
+	}
+	// при добавлении четвертого шарда переезжает около четверти ключей, а не почти все как у Modulo
+	if moved > keys/2 {
+		t.Errorf("too many keys moved %d", moved)
+	}
+
+	if _, err := ShardKey(-1); err == nil {
+		t.Errorf("negative key accepted")
+	}
+	if _, err := ShardKey(1.5); err == nil {
+		t.Errorf("float key accepted")
+	}
+	if a, _ := ShardKey("denis"); a != hashString("denis") {
+		t.Errorf("bad string key")
+	}
+}
+
+func newShards(t *testing.T, n int) ([]*sql.DB, []*dbnamestest.Mock) {
+	dbs := make([]*sql.DB, n)
+	mocks := make([]*dbnamestest.Mock, n)
+	for i := range dbs {
+		db, mock, err := dbnamestest.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		dbs[i], mocks[i] = db, mock
+	}
+	return dbs, mocks
+}
+
+func TestRouter(t *testing.T) {
+	dbs, mocks := newShards(t, 2)
+	router, err := NewRouter(Modulo(2), dbs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db, err := router.For(&DBEvent{UserId: 3}); err != nil || db != dbs[1] {
+		t.Errorf("bad route %v", err)
+	}
+	if _, err := router.For(DBAuth{}); err == nil {
+		t.Errorf("struct without shardkey routed")
+	}
+	if _, err := NewRouter(Modulo(2)); err == nil {
+		t.Errorf("router without shards")
+	}
+	if _, err := NewRouter(nil, dbs...); err == nil {
+		t.Errorf("router without strategy")
+	}
+	if _, err := NewRouter((*ConsistentHash)(nil), dbs...); err == nil {
+		t.Errorf("router with nil ring")
+	}
+	ranged, _ := NewRouter(NewRangeTable(ShardRange{From: 100, Shard: 5}), dbs...)
+	if _, err := ranged.DB(200); err == nil {
+		t.Errorf("shard out of range accepted")
+	}
+
+	events, err := NewRepo[DBEvent](nil, "event", DIALECTMYSQL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	columns := []string{"id", "user_id", "create"}
+	query := "SELECT `event`.`id`, `event`.`user_id`, `event`.`create` FROM `event` WHERE `event`.`id`>? ORDER BY `event`.`create` DESC LIMIT 3"
+	mocks[0].ExpectQuery(query).WithArgs(0).WillReturnRows(dbnamestest.NewRows(columns...).
+		AddRow(1, 2, "2026-10-19 10:00:00").AddRow(2, 4, "2026-10-19 08:00:00").AddRow(3, 2, "2026-10-19 06:00:00"))
+	mocks[1].ExpectQuery(query).WithArgs(0).WillReturnRows(dbnamestest.NewRows(columns...).
+		AddRow(4, 1, "2026-10-19 09:00:00").AddRow(5, 3, "2026-10-19 07:00:00"))
+	merged, err := FanOut(ctx, router, events, []Predicate{{Column: "id", Operation: MORE, Values: []interface{}{0}}},
+		&FindOptions{OrderBy: "-create", Limit: 2, Offset: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(merged) != 2 || merged[0].Id != 4 || merged[1].Id != 2 {
+		t.Errorf("bad merge %+v", merged)
+	}
+
+	// условие на shardkey идет только на свой шард
+	mocks[1].ExpectQuery("SELECT `event`.`id`, `event`.`user_id`, `event`.`create` FROM `event` WHERE `event`.`user_id`=?").
+		WithArgs(3).WillReturnRows(dbnamestest.NewRows(columns...).AddRow(5, 3, "2026-10-19 07:00:00"))
+	single, err := FanOut(ctx, router, events, []Predicate{{Column: "user_id", Operation: EQUAL, Values: []interface{}{uint32(3)}}}, nil)
+	if err != nil || len(single) != 1 || single[0].Id != 5 {
+		t.Errorf("bad single shard %+v %v", single, err)
+	}
+	for _, mock := range mocks {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// ошибка шарда возвращается с его номером
+	failed := errors.New("failed")
+	mocks[0].ExpectQueryRegexp("^SELECT").WillReturnRows(dbnamestest.NewRows(columns...))
+	mocks[1].ExpectQueryRegexp("^SELECT").WillReturnError(failed)
+	if _, err := FanOut(ctx, router, events, nil, nil); !errors.Is(err, failed) || err.Error() != "shard 1: failed" {
+		t.Errorf("bad shard error %v", err)
+	}
+	if _, err := FanOut(ctx, router, events, nil, &FindOptions{OrderBy: "first_begin"}); !errors.Is(err, BDERRORPARAM) {
+		t.Errorf("bad order accepted %v", err)
+	}
+	if _, err := FanOut(ctx, router, events, nil, &FindOptions{OrderBy: "-create", Fields: []string{"id"}}); !errors.Is(err, BDERRORPARAM) {
+		t.Errorf("order by column not in fields accepted %v", err)
+	}
+	// порядок по типу который не умеем сравнивать потерял бы строки после LIMIT шардов
+	notes, err := NewRepo[DBEventNote](nil, "event_note", DIALECTMYSQL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := FanOut(ctx, router, notes, nil, &FindOptions{OrderBy: "note", Limit: 10}); !errors.Is(err, BDERRORPARAM) {
+		t.Errorf("order by unsortable type accepted %v", err)
+	}
+
+	// чужие и повторные номера шардов отклоняются до запуска fn
+	for _, shards := range [][]int{{5}, {-1}, {0, 0}} {
+		err := router.Each(ctx, shards, func(ctx context.Context, shard int, db *sql.DB) error {
+			t.Errorf("fn called for %v", shards)
+			return nil
+		})
+		if err == nil {
+			t.Errorf("bad shards %v accepted", shards)
+		}
+	}
+}
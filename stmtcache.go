@@ -0,0 +1,200 @@
+package dbnames
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+)
+
+// эта часть файла кеширует подготовленные выражения по тексту запроса
+
+const DefaultStmtCacheSize = 256
+
+type cachedStmt struct {
+	query   string
+	stmt    *sql.Stmt
+	refs    int
+	evicted bool
+}
+
+/*
+кеш подготовленных выражений поверх *sql.DB: каждый текст запроса готовится один раз,
+*sql.Stmt используется из всех горутин, при переполнении вытесняется давно не
+использованное выражение (закрывается когда его перестанут выполнять),
+после ошибки соединения выражение готовится заново, реализует Querier
+*/
+type StmtCache struct {
+	db    *sql.DB
+	size  int
+	mutex sync.Mutex
+	items map[string]*list.Element
+	order *list.List
+}
+
+var _ Querier = (*StmtCache)(nil)
+
+/*
+функция создает кеш на size выражений (DefaultStmtCacheSize если size <= 0)
+*/
+func NewStmtCache(db *sql.DB, size int) *StmtCache {
+	if size <= 0 {
+		size = DefaultStmtCacheSize
+	}
+	return &StmtCache{db: db, size: size, items: make(map[string]*list.Element), order: list.New()}
+}
+
+// Len число выражений в кеше
+func (c *StmtCache) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.order.Len()
+}
+
+/*
+функция закрывает все выражения кеша, занятые сейчас закроются после выполнения,
+после Close кешем можно пользоваться дальше
+*/
+func (c *StmtCache) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	var closeErr error
+	for c.order.Len() > 0 {
+		if err := c.evict(c.order.Back()); err != nil && closeErr == nil {
+			closeErr = err
+		}
+	}
+	return closeErr
+}
+
+/*
+внутрення функция пакета, берет выражение из кеша или готовит его, выражение
+занято (не закроется при вытеснении) до вызова release
+*/
+func (c *StmtCache) acquire(ctx context.Context, query string) (*cachedStmt, error) {
+	c.mutex.Lock()
+	if elem, ok := c.items[query]; ok {
+		c.order.MoveToFront(elem)
+		cached := elem.Value.(*cachedStmt)
+		cached.refs++
+		c.mutex.Unlock()
+		return cached, nil
+	}
+	c.mutex.Unlock()
+	// готовим без блокировки, параллельно подготовленный дубль закрываем
+	stmt, err := c.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if elem, ok := c.items[query]; ok {
+		stmt.Close()
+		c.order.MoveToFront(elem)
+		cached := elem.Value.(*cachedStmt)
+		cached.refs++
+		return cached, nil
+	}
+	cached := &cachedStmt{query: query, stmt: stmt, refs: 1}
+	c.items[query] = c.order.PushFront(cached)
+	for c.order.Len() > c.size {
+		c.evict(c.order.Back())
+	}
+	return cached, nil
+}
+
+func (c *StmtCache) release(cached *cachedStmt) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	cached.refs--
+	if cached.evicted && cached.refs == 0 {
+		cached.stmt.Close()
+	}
+}
+
+/*
+внутрення функция пакета, убирает выражение из кеша после ошибки соединения,
+чтобы следующий вызов подготовил его заново
+*/
+func (c *StmtCache) invalidate(cached *cachedStmt) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if elem, ok := c.items[cached.query]; ok && elem.Value == cached {
+		c.evict(elem)
+	}
+}
+
+// вызывается под mutex
+func (c *StmtCache) evict(elem *list.Element) error {
+	cached := c.order.Remove(elem).(*cachedStmt)
+	delete(c.items, cached.query)
+	cached.evicted = true
+	if cached.refs == 0 {
+		return cached.stmt.Close()
+	}
+	return nil
+}
+
+/*
+запрос можно повторить на новом выражении, если ошибка про соединение;
+для Exec только driver.ErrBadConn, при котором запрос точно не ушел на сервер
+*/
+func connectionLost(err error, exec bool) bool {
+	if exec {
+		return errors.Is(err, driver.ErrBadConn)
+	}
+	code, ok := Code(err)
+	return ok && code == BDERRORLINK
+}
+
+func (c *StmtCache) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	for attempt := 0; ; attempt++ {
+		cached, err := c.acquire(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		result, err := cached.stmt.ExecContext(ctx, args...)
+		c.release(cached)
+		if err == nil || attempt > 0 || !connectionLost(err, true) {
+			return result, err
+		}
+		c.invalidate(cached)
+	}
+}
+
+func (c *StmtCache) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	for attempt := 0; ; attempt++ {
+		cached, err := c.acquire(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		// открытые rows держат выражение сами, закрыть его после release можно
+		rows, err := cached.stmt.QueryContext(ctx, args...)
+		c.release(cached)
+		if err == nil || attempt > 0 || !connectionLost(err, false) {
+			return rows, err
+		}
+		c.invalidate(cached)
+	}
+}
+
+/*
+ошибка подготовки выражения здесь не возвращается сразу (у *sql.Row нет конструктора
+с ошибкой), поэтому при ней запрос выполняется без кеша и вернет ту же ошибку в Scan
+*/
+func (c *StmtCache) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	for attempt := 0; ; attempt++ {
+		cached, err := c.acquire(ctx, query)
+		if err != nil {
+			return c.db.QueryRowContext(ctx, query, args...)
+		}
+		row := cached.stmt.QueryRowContext(ctx, args...)
+		c.release(cached)
+		if attempt > 0 || !connectionLost(row.Err(), false) {
+			return row
+		}
+		c.invalidate(cached)
+	}
+}
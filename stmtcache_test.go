@@ -0,0 +1,111 @@
+package dbnames
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/denisbdn/dbnames/dbnamestest"
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestStmtCache(t *testing.T) {
+	db, mock, err := dbnamestest.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	ctx := context.Background()
+	cache := NewStmtCache(db, 2)
+	queries := []string{
+		"SELECT `data` FROM `auth` WHERE `user_id` = ?",
+		"SELECT `data` FROM `auth` WHERE `user_id` = ? AND `auth_type` = ?",
+		"UPDATE `auth` SET `data` = ? WHERE `user_id` = ?",
+	}
+	mock.ExpectQuery(queries[0]).WithArgs(1).WillReturnRows(dbnamestest.NewRows("data").AddRow("a"))
+	mock.ExpectQuery(queries[1]).WithArgs(1, 0).WillReturnRows(dbnamestest.NewRows("data").AddRow("b"))
+	mock.ExpectQuery(queries[0]).WithArgs(2).WillReturnRows(dbnamestest.NewRows("data").AddRow("c"))
+	mock.ExpectExec(queries[2]).WithArgs("d", 1).WillReturnResult(0, 1)
+	var data string
+	if err := cache.QueryRowContext(ctx, queries[0], 1).Scan(&data); err != nil || data != "a" {
+		t.Fatal(data, err)
+	}
+	rows, err := cache.QueryContext(ctx, queries[1], 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		rows.Scan(&data)
+	}
+	rows.Close()
+	if err := cache.QueryRowContext(ctx, queries[0], 2).Scan(&data); err != nil || data != "c" {
+		t.Fatal(data, err)
+	}
+	if prepared, closed := mock.Statements(); prepared != 2 || closed != 0 {
+		t.Errorf("bad statements %d %d", prepared, closed)
+	}
+	// третье выражение вытесняет давно не использованное второе
+	if _, err := cache.ExecContext(ctx, queries[2], "d", 1); err != nil {
+		t.Fatal(err)
+	}
+	if prepared, closed := mock.Statements(); prepared != 3 || closed != 1 || cache.Len() != 2 {
+		t.Errorf("bad statements %d %d %d", prepared, closed, cache.Len())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+
+	// после ошибки соединения выражение готовится заново и запрос повторяется
+	mock.ExpectQuery(queries[0]).WithArgs(3).WillReturnError(mysql.ErrInvalidConn)
+	mock.ExpectQuery(queries[0]).WithArgs(3).WillReturnRows(dbnamestest.NewRows("data").AddRow("e"))
+	if err := cache.QueryRowContext(ctx, queries[0], 3).Scan(&data); err != nil || data != "e" {
+		t.Fatal(data, err)
+	}
+	if prepared, closed := mock.Statements(); prepared != 4 || closed != 2 {
+		t.Errorf("bad statements %d %d", prepared, closed)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cache.Close(); err != nil || cache.Len() != 0 {
+		t.Error(err)
+	}
+	if prepared, closed := mock.Statements(); prepared != closed {
+		t.Errorf("statements left open %d %d", prepared, closed)
+	}
+}
+
+func TestStmtCacheConcurrent(t *testing.T) {
+	db, mock, err := dbnamestest.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	cache := NewStmtCache(db, 1)
+	const workers = 8
+	for i := 0; i < workers*2; i++ {
+		mock.ExpectExecRegexp("^UPDATE `auth`")
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for _, query := range []string{"UPDATE `auth` SET `data` = ?", "UPDATE `auth` SET `auth_type` = ?"} {
+				if _, err := cache.ExecContext(context.Background(), query, i); err != nil {
+					t.Error(err)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	cache.Close()
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+	if prepared, closed := mock.Statements(); prepared != closed {
+		t.Errorf("statements left open %d %d", prepared, closed)
+	}
+}
@@ -0,0 +1,87 @@
+package dbnames
+
+import (
+	"reflect"
+	"sync"
+)
+
+// эта часть файла позволяет моделям самим знать имя своей таблицы
+
+/*
+имя таблицы вместо которого построители берут имя из модели (см ModelTable),
+если у модели имени нет то столбцы идут без таблицы, как с пустой строкой
+
+	fields := BuildFields(TABLEAUTO, DBAuth{})
+*/
+const TABLEAUTO = "*"
+
+/*
+модель знает имя своей таблицы, метод на значении (не на указателе),
+он может зависеть от полей для шардированных таблиц
+*/
+type TableNamer interface {
+	TableName() string
+}
+
+/*
+функция выдает имя таблицы по значению модели, например auth_202610 по полю create
+*/
+type TableResolver func(model interface{}) string
+
+var tableResolvers sync.Map
+
+/*
+функция регистрирует resolver для типа модели (model это значение или указатель),
+resolver важнее TableNamer, nil удаляет регистрацию
+*/
+func RegisterTableResolver(model interface{}, resolver TableResolver) {
+	t := indirectType(reflect.TypeOf(model))
+	if resolver == nil {
+		tableResolvers.Delete(t)
+		return
+	}
+	tableResolvers.Store(t, resolver)
+}
+
+/*
+функция выдает имя таблицы модели через зарегистрированный TableResolver
+или метод TableName, false если модель имени не знает
+*/
+func ModelTable(model interface{}) (string, bool) {
+	if model == nil {
+		return "", false
+	}
+	value := reflect.ValueOf(model)
+	if value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			model = reflect.New(value.Type().Elem()).Elem().Interface()
+		} else {
+			model = value.Elem().Interface()
+		}
+	}
+	if resolver, ok := tableResolvers.Load(reflect.TypeOf(model)); ok {
+		return resolver.(TableResolver)(model), true
+	}
+	if namer, ok := model.(TableNamer); ok {
+		return namer.TableName(), true
+	}
+	return "", false
+}
+
+/*
+внутрення функция пакета, подставляет имя таблицы модели вместо TABLEAUTO
+*/
+func resolveTable(table string, model interface{}) string {
+	if table != TABLEAUTO {
+		return table
+	}
+	name, _ := ModelTable(model)
+	return name
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	if t != nil && t.Kind() == reflect.Pointer {
+		return t.Elem()
+	}
+	return t
+}
@@ -0,0 +1,95 @@
+package dbnames
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/denisbdn/dbnames/dbnamestest"
+)
+
+func TestTableNamer(t *testing.T) {
+	if strings.Join(BuildFields(TABLEAUTO, DBAuth{}), ", ") != strings.Join(BuildFields(DBAuthTable, DBAuth{}), ", ") {
+		t.Errorf("bad auto fields %v", BuildFields(TABLEAUTO, DBAuth{}))
+	}
+	cond := BuildConditions(TABLEAUTO, DBAuth{UserId: 5}, EQUAL)
+	if len(cond) != 1 || cond[0] != " `auth`.`user_id`=5" {
+		t.Errorf("bad auto conditions %v", cond)
+	}
+	if order, err := BuildOrderBy(TABLEAUTO, DBAuth{}, "-create"); err != nil || order != "ORDER BY `auth`.`create` DESC" {
+		t.Errorf("bad auto order %s %v", order, err)
+	}
+	if query, _, err := BuildSelect(TABLEAUTO, DBAuth{}, DIALECTMYSQL, []string{"user_id"}); err != nil || query != "SELECT `auth`.`user_id` FROM `auth`" {
+		t.Errorf("bad auto select %s %v", query, err)
+	}
+	if name, ok := ModelTable((*DBAuth)(nil)); !ok || name != DBAuthTable {
+		t.Errorf("bad model table %s", name)
+	}
+	// модель без имени дает столбцы без таблицы
+	if fields := BuildFields(TABLEAUTO, DBData{}); fields[0] != "`crc`" {
+		t.Errorf("bad unnamed fields %v", fields)
+	}
+	if _, err := BuildCreateTable(TABLEAUTO, DBData{}, DIALECTMYSQL); err == nil {
+		t.Errorf("table without name accepted")
+	}
+	if table, err := TableFromStruct(TABLEAUTO, DBAuth{}, DIALECTMYSQL); err != nil || table.Name != DBAuthTable {
+		t.Errorf("bad auto table %v", err)
+	}
+}
+
+func sessionShard(model interface{}) string {
+	created := time.Time(model.(DBSession).Create)
+	if created.IsZero() {
+		return DBSessionTable
+	}
+	return fmt.Sprintf("%s_%04d%02d", DBSessionTable, created.Year(), created.Month())
+}
+
+func TestTableResolver(t *testing.T) {
+	RegisterTableResolver(DBSession{}, sessionShard)
+	defer RegisterTableResolver(DBSession{}, nil)
+	session := DBSession{Id: 1, Create: MYSQLDATETIME(time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC))}
+	if name, ok := ModelTable(&session); !ok || name != "session_202610" {
+		t.Errorf("bad shard %s", name)
+	}
+	if cond := BuildConditions(TABLEAUTO, session, EQUAL); cond[0] != " `session_202610`.`id`=1" {
+		t.Errorf("bad shard conditions %v", cond)
+	}
+
+	db, mock, err := dbnamestest.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	sessions, err := NewRepo[DBSession](db, TABLEAUTO, DIALECTMYSQL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock.ExpectExec("INSERT INTO `session_202610` (`id`, `user_id`, `token`, `create`) VALUES (?, ?, ?, ?)").WillReturnResult(1, 1)
+	mock.ExpectExec("DELETE FROM `session_202609` WHERE `session_202609`.`id`=?").WithArgs(1).WillReturnResult(0, 1)
+	if err := sessions.Insert(context.Background(), &session); err != nil {
+		t.Error(err)
+	}
+	if _, err := sessions.InTable("session_202609").Delete(context.Background(), 1); err != nil {
+		t.Error(err)
+	}
+	// по ключу шард не определить, нужен InTable
+	if _, err := sessions.Get(context.Background(), 1); !errors.Is(err, BDERRORPARAM) {
+		t.Errorf("get without table %v", err)
+	}
+	if _, err := sessions.Count(context.Background()); !errors.Is(err, BDERRORPARAM) {
+		t.Errorf("count without table %v", err)
+	}
+	if _, err := sessions.Delete(context.Background(), 1); !errors.Is(err, BDERRORPARAM) {
+		t.Errorf("delete without table %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewRepo[DBData](db, TABLEAUTO, DIALECTMYSQL); err == nil {
+		t.Errorf("repo without table accepted")
+	}
+}
@@ -0,0 +1,270 @@
+package dbnames
+
+import (
+	"container/list"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// эта часть файла кеширует готовые тексты SELECT, повторные вызовы только собирают параметры
+
+type selectKey struct {
+	model   reflect.Type
+	table   string
+	fields  string
+	shape   string
+	dialect Dialect
+}
+
+/*
+сколько готовых текстов SELECT (и отдельно SelectByPK) хранится в кеше, при переполнении
+вытесняется давно не использованный текст, иначе каждая новая длина списка IN и каждая
+таблица шарда (session_202610) навсегда оставались бы в памяти
+*/
+const DefaultSelectCacheSize = 1024
+
+type templateEntry[K comparable] struct {
+	key   K
+	query string
+}
+
+/*
+внутренний LRU кеш текстов запросов, как у StmtCache, но без подсчета ссылок:
+строки не нужно закрывать
+*/
+type templateCache[K comparable] struct {
+	size  int
+	mutex sync.Mutex
+	items map[K]*list.Element
+	order *list.List
+}
+
+func newTemplateCache[K comparable](size int) *templateCache[K] {
+	return &templateCache[K]{size: size, items: make(map[K]*list.Element), order: list.New()}
+}
+
+func (c *templateCache[K]) load(key K) (string, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	elem, ok := c.items[key]
+	if !ok {
+		return "", false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*templateEntry[K]).query, true
+}
+
+func (c *templateCache[K]) store(key K, query string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if elem, ok := c.items[key]; ok {
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.items[key] = c.order.PushFront(&templateEntry[K]{key: key, query: query})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*templateEntry[K]).key)
+	}
+}
+
+func (c *templateCache[K]) len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.order.Len()
+}
+
+var selectTemplates = newTemplateCache[selectKey](DefaultSelectCacheSize)
+
+/*
+функция строит SELECT по столбцам структуры model (все или только fields, как BuildFields)
+с условиями preds через AND, значения условий не подставляются в текст, а возвращаются
+параметрами для ? (или $n для postgres), поэтому текст запроса зависит только от типа,
+таблицы, набора полей, вида условий (столбец, оператор, число значений) и диалекта
+и после первого вызова берется из кеша
+
+	query, args, err := BuildSelect(DBAuthTable, DBAuth{}, DIALECTMYSQL, nil, AuthCols.UserId.In(1, 2))
+	// SELECT `auth`.`user_id`, ... FROM `auth` WHERE `auth`.`user_id` IN (?, ?)
+	rows, err := db.QueryContext(ctx, query, args...)
+*/
+func BuildSelect(table string, model interface{}, dialect Dialect, fields []string, preds ...Predicate) (string, []interface{}, error) {
+	table = resolveTable(table, model)
+	modelType := reflect.TypeOf(model)
+	if modelType == nil {
+		return "", nil, fmt.Errorf("model is nil")
+	}
+	if modelType.Kind() == reflect.Pointer {
+		modelType = modelType.Elem()
+	}
+	if modelType.Kind() != reflect.Struct {
+		return "", nil, fmt.Errorf("model %s is not a struct", modelType)
+	}
+	key := selectKey{model: modelType, table: table, fields: strings.Join(fields, ","), shape: predicateShape(preds), dialect: dialect}
+	args := predicateArgs(preds)
+	if query, ok := selectTemplates.load(key); ok {
+		return query, args, nil
+	}
+	query, err := renderSelect(modelType, table, dialect, fields, preds)
+	if err != nil {
+		return "", nil, err
+	}
+	selectTemplates.store(key, query)
+	return query, args, nil
+}
+
+/*
+внутрення функция пакета, вид условий для ключа кеша: столбец, оператор и число значений
+*/
+func predicateShape(preds []Predicate) string {
+	var shape strings.Builder
+	for _, pred := range preds {
+		shape.WriteString(pred.Column)
+		shape.WriteByte(0)
+		shape.WriteString(strconv.Itoa(int(pred.Operation)))
+		shape.WriteByte(0)
+		if pred.Operation != ISNULL && pred.Operation != ISNOTNULL {
+			shape.WriteString(strconv.Itoa(len(pred.Values)))
+		}
+		shape.WriteByte(0)
+	}
+	return shape.String()
+}
+
+func renderSelect(modelType reflect.Type, table string, dialect Dialect, fields []string, preds []Predicate) (string, error) {
+	columns := make([]string, 0)
+	for _, column := range columnsOf(modelType) {
+		if len(fields) > 0 && !containsString(fields, column) {
+			continue
+		}
+		columns = append(columns, qualifiedName(dialect, table, column))
+	}
+	if len(columns) == 0 {
+		return "", fmt.Errorf("no columns of %s selected", modelType)
+	}
+	where, err := renderWhere(table, dialect, preds)
+	if err != nil {
+		return "", err
+	}
+	return "SELECT " + strings.Join(columns, ", ") + " FROM " + dialect.Quote(table) + where, nil
+}
+
+/*
+внутрення функция пакета, блок " WHERE ..." с параметрами вместо значений
+или пустая строка если условий нет
+*/
+func renderWhere(table string, dialect Dialect, preds []Predicate) (string, error) {
+	if len(preds) == 0 {
+		return "", nil
+	}
+	conds := make([]string, 0, len(preds))
+	n := 0
+	for _, pred := range preds {
+		cond, err := pred.renderPlaceholders(table, dialect, &n)
+		if err != nil {
+			return "", err
+		}
+		conds = append(conds, cond)
+	}
+	return " WHERE" + strings.Join(conds, " AND"), nil
+}
+
+/*
+внутрення функция пакета, параметры запроса для renderWhere в том же порядке
+*/
+func predicateArgs(preds []Predicate) []interface{} {
+	args := make([]interface{}, 0, len(preds))
+	for _, pred := range preds {
+		if pred.Operation != ISNULL && pred.Operation != ISNOTNULL {
+			args = append(args, pred.Values...)
+		}
+	}
+	return args
+}
+
+/*
+внутрення функция пакета, имя столбца в кавычках диалекта, для MySQL то же что fullFieldName
+*/
+func qualifiedName(dialect Dialect, table string, column string) string {
+	if len(table) > 0 {
+		return dialect.Quote(table) + "." + dialect.Quote(column)
+	}
+	return dialect.Quote(column)
+}
+
+func containsString(list []string, str string) bool {
+	for _, item := range list {
+		if item == str {
+			return true
+		}
+	}
+	return false
+}
+
+type pkKey struct {
+	model   reflect.Type
+	table   string
+	dialect Dialect
+}
+
+var pkSelects = newTemplateCache[pkKey](DefaultSelectCacheSize)
+
+/*
+функция выдает SELECT всех столбцов T по первичному ключу (поля с dbddl:"pk" в порядке
+структуры, параметры запроса идут в том же порядке), повторный вызов не выделяет память
+
+	query, err := SelectByPK[DBSession](DBSessionTable, DIALECTMYSQL)
+	// SELECT `session`.`id`, ... FROM `session` WHERE `session`.`id`=?
+	row := db.QueryRowContext(ctx, query, id)
+*/
+func SelectByPK[T any](table string, dialect Dialect) (string, error) {
+	if table == TABLEAUTO {
+		var model T
+		table = resolveTable(table, model)
+	}
+	key := pkKey{model: reflect.TypeOf((*T)(nil)).Elem(), table: table, dialect: dialect}
+	if query, ok := pkSelects.load(key); ok {
+		return query, nil
+	}
+	if key.model.Kind() != reflect.Struct {
+		return "", fmt.Errorf("model %s is not a struct", key.model)
+	}
+	preds := make([]Predicate, 0, 1)
+	for _, column := range primaryKeyOf(key.model) {
+		preds = append(preds, Predicate{Column: column, Operation: EQUAL, Values: []interface{}{nil}})
+	}
+	if len(preds) == 0 {
+		return "", fmt.Errorf("model %s has no dbddl:\"pk\" fields", key.model)
+	}
+	query, err := renderSelect(key.model, table, dialect, nil, preds)
+	if err != nil {
+		return "", err
+	}
+	pkSelects.store(key, query)
+	return query, nil
+}
+
+/*
+внутрення функция пакета, теги db полей первичного ключа (dbddl:"pk")
+*/
+func primaryKeyOf(t reflect.Type) []string {
+	columns := make([]string, 0, 1)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		dbFieldName, find := field.Tag.Lookup("db")
+		if !find {
+			continue
+		}
+		options, _ := field.Tag.Lookup("dbddl")
+		for _, option := range splitDDLOptions(options) {
+			if option == "pk" {
+				columns = append(columns, dbFieldName)
+				break
+			}
+		}
+	}
+	return columns
+}
@@ -0,0 +1,149 @@
+package dbnames
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/denisbdn/dbnames/dbnamestest"
+)
+
+type DBSession struct {
+	Id     uint64        `db:"id" dbddl:"pk,autoinc"`
+	UserId UserIdType    `db:"user_id" dbddl:"index"`
+	Token  string        `db:"token" dbtype:"varchar(64)"`
+	Create MYSQLDATETIME `db:"create"`
+}
+
+const DBSessionTable = "session"
+
+func TestBuildSelect(t *testing.T) {
+	query, args, err := BuildSelect(DBAuthTable, DBAuth{}, DIALECTMYSQL, []string{"user_id", "data"},
+		AuthCols.UserId.In(1, 2), AuthCols.Data.IsNotNull(), AuthCols.Type.Eq(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query != "SELECT `auth`.`user_id`, `auth`.`data` FROM `auth` WHERE `auth`.`user_id` IN (?, ?) AND `auth`.`data` IS NOT NULL AND `auth`.`auth_type`=?" {
+		t.Errorf("bad query %s", query)
+	}
+	if len(args) != 3 || args[0] != UserIdType(1) || args[2] != AuthType(3) {
+		t.Errorf("bad args %v", args)
+	}
+	// тот же вид условий с другими значениями берется из кеша
+	again, args, err := BuildSelect(DBAuthTable, &DBAuth{}, DIALECTMYSQL, []string{"user_id", "data"},
+		AuthCols.UserId.In(5, 6), AuthCols.Data.IsNotNull(), AuthCols.Type.Eq(0))
+	if err != nil || again != query || args[0] != UserIdType(5) || args[2] != AuthType(0) {
+		t.Errorf("bad cached query %s %v %v", again, args, err)
+	}
+	// другое число значений IN это другой текст
+	if other, _, _ := BuildSelect(DBAuthTable, DBAuth{}, DIALECTMYSQL, []string{"user_id", "data"},
+		AuthCols.UserId.In(5), AuthCols.Data.IsNotNull(), AuthCols.Type.Eq(0)); other == query {
+		t.Errorf("shape ignored %s", other)
+	}
+	created := MYSQLDATETIME(time.Date(2023, 5, 12, 21, 41, 23, 0, time.UTC))
+	query, args, err = BuildSelect(DBAuthTable, DBAuth{}, DIALECTPOSTGRES, []string{"user_id"}, AuthCols.Create.Gte(created), AuthCols.UserId.NotIn(1, 2))
+	if err != nil || query != `SELECT "auth"."user_id" FROM "auth" WHERE "auth"."create">=$1 AND "auth"."user_id" NOT IN ($2, $3)` || len(args) != 3 {
+		t.Errorf("bad postgres query %s %v %v", query, args, err)
+	}
+	if value, err := args[0].(MYSQLDATETIME).Value(); err != nil || !value.(time.Time).Equal(time.Time(created)) {
+		t.Errorf("bad value %v %v", value, err)
+	}
+	if value, _ := (MYSQLDATETIME{}).Value(); value == nil || value.(time.Time).IsZero() {
+		t.Errorf("zero time is not now %v", value)
+	}
+	if value, err := driver.DefaultParameterConverter.ConvertValue((*MYSQLDATETIME)(nil)); err != nil || value != nil {
+		t.Errorf("nil pointer is not NULL %v %v", value, err)
+	}
+	if _, _, err := BuildSelect(DBAuthTable, DBAuth{}, DIALECTMYSQL, nil, AuthCols.UserId.In()); err == nil {
+		t.Errorf("empty IN accepted")
+	}
+	if _, _, err := BuildSelect(DBAuthTable, DBAuth{}, DIALECTMYSQL, []string{"first_begin"}); err == nil {
+		t.Errorf("unknown field accepted")
+	}
+}
+
+func TestTemplateCache(t *testing.T) {
+	cache := newTemplateCache[int](2)
+	cache.store(1, "a")
+	cache.store(2, "b")
+	cache.load(1)
+	cache.store(3, "c")
+	if cache.len() != 2 {
+		t.Errorf("cache size %d", cache.len())
+	}
+	if _, ok := cache.load(2); ok {
+		t.Errorf("least recently used not evicted")
+	}
+	if query, ok := cache.load(1); !ok || query != "a" {
+		t.Errorf("recently used evicted %s", query)
+	}
+	// длины IN не копятся сверх размера кеша
+	for n := 1; n <= DefaultSelectCacheSize+10; n++ {
+		if _, _, err := BuildSelect(DBAuthTable, DBAuth{}, DIALECTMYSQL, nil, AuthCols.UserId.In(make([]UserIdType, n)...)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if selectTemplates.len() > DefaultSelectCacheSize {
+		t.Errorf("select cache grew to %d", selectTemplates.len())
+	}
+}
+
+func TestSelectByPK(t *testing.T) {
+	query, err := SelectByPK[DBSession](DBSessionTable, DIALECTMYSQL)
+	if err != nil || query != "SELECT `session`.`id`, `session`.`user_id`, `session`.`token`, `session`.`create` FROM `session` WHERE `session`.`id`=?" {
+		t.Errorf("bad query %s %v", query, err)
+	}
+	if _, err := SelectByPK[DBAuth](DBAuthTable, DIALECTMYSQL); err == nil {
+		t.Errorf("struct without pk accepted")
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		SelectByPK[DBSession](DBSessionTable, DIALECTMYSQL)
+	})
+	if allocs != 0 {
+		t.Errorf("cached SelectByPK allocates %v", allocs)
+	}
+
+	db, mock, err := dbnamestest.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	mock.ExpectQuery(query).WithArgs(7).WillReturnRows(
+		dbnamestest.NewRows("id", "user_id", "token", "create").AddRow(7, 184216, "abc", "2023-05-12 21:41:23"))
+	rows, err := db.QueryContext(context.Background(), query, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	res, err := New(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := DBSession{}
+	for res.Next() {
+		res.Scan()
+		FillByDBResult(res, &session)
+	}
+	if session.Id != 7 || session.UserId != 184216 || session.Token != "abc" {
+		t.Errorf("bad session %+v", session)
+	}
+}
+
+func BenchmarkSelectByPK(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := SelectByPK[DBSession](DBSessionTable, DIALECTMYSQL); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkBuildSelect(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := BuildSelect(DBAuthTable, DBAuth{}, DIALECTMYSQL, nil, AuthCols.UserId.Eq(UserIdType(i)), AuthCols.Type.In(1, 2)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
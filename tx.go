@@ -0,0 +1,228 @@
+package dbnames
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// Querier общий интерфейс *sql.DB, *sql.Tx и *sql.Conn, его принимают функции пакета которые ходят в базу
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// TxBeginner умеет начинать транзакцию, им являются *sql.DB и *sql.Conn
+type TxBeginner interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+var (
+	_ Querier    = (*sql.DB)(nil)
+	_ Querier    = (*sql.Tx)(nil)
+	_ Querier    = (*sql.Conn)(nil)
+	_ TxBeginner = (*sql.DB)(nil)
+	_ TxBeginner = (*sql.Conn)(nil)
+	_ Querier    = (*Tx)(nil)
+)
+
+const (
+	DefaultTxAttempts = 3
+	DefaultTxBackoff  = 50 * time.Millisecond
+)
+
+// TxOptions параметры WithTx, нулевые MaxAttempts и Backoff заменяются значениями по умолчанию
+type TxOptions struct {
+	sql.TxOptions
+	// сколько раз всего выполнить функцию, если транзакция падает на deadlock или lock wait timeout
+	MaxAttempts int
+	// пауза перед второй попыткой, дальше удваивается, к каждой паузе добавляется случайный разброс
+	Backoff time.Duration
+	// синтаксис точек сохранения для вложенных InTx
+	Dialect Dialect
+}
+
+/*
+функция выполняет fn в транзакции: коммит если fn вернула nil, откат при ошибке или панике
+(панику пробрасывает дальше), если ошибка fn или коммита классифицируется как BDERRORDEADLOCK
+или BDERRORLOCKTIMEOUT то fn выполняется заново в новой транзакции, поэтому fn не должна иметь
+побочных эффектов вне транзакции, opts может быть nil
+*/
+func WithTx(ctx context.Context, db TxBeginner, opts *TxOptions, fn func(tx *sql.Tx) error) error {
+	if opts == nil {
+		opts = &TxOptions{}
+	}
+	attempts := opts.MaxAttempts
+	if attempts <= 0 {
+		attempts = DefaultTxAttempts
+	}
+	backoff := opts.Backoff
+	if backoff <= 0 {
+		backoff = DefaultTxBackoff
+	}
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			if ctxErr := sleepContext(ctx, jitter(backoff<<(attempt-1))); ctxErr != nil {
+				return fmt.Errorf("%w (retry stopped: %v)", err, ctxErr)
+			}
+		}
+		err = runTx(ctx, db, &opts.TxOptions, fn)
+		if !retryable(err) {
+			return err
+		}
+	}
+	return err
+}
+
+/*
+внутрення функция пакета, одна попытка WithTx
+*/
+func runTx(ctx context.Context, db TxBeginner, opts *sql.TxOptions, fn func(tx *sql.Tx) error) (err error) {
+	tx, err := db.BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func retryable(err error) bool {
+	code, ok := Code(err)
+	return ok && (code == BDERRORDEADLOCK || code == BDERRORLOCKTIMEOUT)
+}
+
+// jitter возвращает случайную паузу в диапазоне [d/2, d)
+func jitter(d time.Duration) time.Duration {
+	half := d / 2
+	if half <= 0 {
+		return d
+	}
+	return half + time.Duration(rand.Int63n(int64(half)))
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// эта часть файла про вложенные транзакции на точках сохранения
+
+/*
+транзакция InTx, передается через context во вложенные вызовы, реализует Querier,
+одновременно из нескольких горутин ее использовать нельзя
+*/
+type Tx struct {
+	tx         *sql.Tx
+	dialect    Dialect
+	savepoints int
+	failed     error
+}
+
+type txContextKey struct{}
+
+// TxFromContext возвращает транзакцию InTx из контекста
+func TxFromContext(ctx context.Context) (*Tx, bool) {
+	tx, ok := ctx.Value(txContextKey{}).(*Tx)
+	return tx, ok && tx != nil
+}
+
+// Tx возвращает транзакцию database/sql, коммит и откат делает только InTx
+func (tx *Tx) Tx() *sql.Tx {
+	return tx.tx
+}
+
+func (tx *Tx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return tx.tx.ExecContext(ctx, query, args...)
+}
+
+func (tx *Tx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return tx.tx.QueryContext(ctx, query, args...)
+}
+
+func (tx *Tx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return tx.tx.QueryRowContext(ctx, query, args...)
+}
+
+/*
+функция выполняет fn в транзакции: если в ctx уже есть транзакция InTx, то fn работает
+внутри нее на точке сохранения SAVEPOINT sp_n (при ошибке ROLLBACK TO SAVEPOINT,
+при успехе RELEASE SAVEPOINT), иначе открывается новая транзакция как в WithTx
+(с повтором при deadlock) и коммитит ее только этот внешний вызов,
+fn должна использовать переданный ей ctx, чтобы вложенные вызовы видели транзакцию
+*/
+func InTx(ctx context.Context, db TxBeginner, opts *TxOptions, fn func(ctx context.Context, tx *Tx) error) error {
+	if tx, ok := TxFromContext(ctx); ok {
+		return tx.nested(ctx, fn)
+	}
+	dialect := DIALECTMYSQL
+	if opts != nil {
+		dialect = opts.Dialect
+	}
+	return WithTx(ctx, db, opts, func(sqlTx *sql.Tx) error {
+		tx := &Tx{tx: sqlTx, dialect: dialect}
+		if err := fn(context.WithValue(ctx, txContextKey{}, tx), tx); err != nil {
+			return err
+		}
+		// вложенная область не откатилась, коммитить нельзя даже если fn ошибку проглотила
+		return tx.failed
+	})
+}
+
+/*
+внутрення функция пакета, вложенная область на точке сохранения,
+deadlock здесь не повторяется: он откатывает всю транзакцию и повторит ее внешний InTx,
+если ROLLBACK TO SAVEPOINT не удался то ошибки объединяются, а транзакция помечается
+сломанной и внешний InTx ее откатит
+*/
+func (tx *Tx) nested(ctx context.Context, fn func(ctx context.Context, tx *Tx) error) (err error) {
+	tx.savepoints++
+	name := fmt.Sprintf("sp_%d", tx.savepoints)
+	if _, err := tx.tx.ExecContext(ctx, tx.dialect.Savepoint(name)); err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.rollbackTo(ctx, name)
+			panic(p)
+		}
+	}()
+	if err := fn(ctx, tx); err != nil {
+		if rbErr := tx.rollbackTo(ctx, name); rbErr != nil {
+			return errors.Join(err, rbErr)
+		}
+		return err
+	}
+	_, err = tx.tx.ExecContext(ctx, tx.dialect.ReleaseSavepoint(name))
+	return err
+}
+
+func (tx *Tx) rollbackTo(ctx context.Context, name string) error {
+	_, err := tx.tx.ExecContext(ctx, tx.dialect.RollbackToSavepoint(name))
+	if err != nil {
+		err = fmt.Errorf("rollback to savepoint %s: %w", name, err)
+		if tx.failed == nil {
+			tx.failed = err
+		}
+	}
+	return err
+}
@@ -0,0 +1,198 @@
+package dbnames
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/denisbdn/dbnames/dbnamestest"
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestWithTx(t *testing.T) {
+	db, mock, err := dbnamestest.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	update := "UPDATE `auth` SET `auth_type` = ? WHERE `user_id` = ?"
+	opts := &TxOptions{Backoff: time.Millisecond}
+
+	// deadlock на первой попытке, вторая успешна
+	mock.ExpectBegin()
+	mock.ExpectExec(update).WithArgs(1, 184216).WillReturnError(&mysql.MySQLError{Number: 1213, Message: "Deadlock found"})
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectExec(update).WithArgs(1, 184216).WillReturnResult(0, 1)
+	mock.ExpectCommit()
+	calls := 0
+	err = WithTx(ctx, db, opts, func(tx *sql.Tx) error {
+		calls++
+		_, err := tx.ExecContext(ctx, update, 1, 184216)
+		return err
+	})
+	if err != nil || calls != 2 {
+		t.Errorf("bad retry %v %d", err, calls)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+
+	// прочие ошибки не повторяются
+	mock.ExpectBegin()
+	mock.ExpectExec(update).WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry"})
+	mock.ExpectRollback()
+	calls = 0
+	err = WithTx(ctx, db, opts, func(tx *sql.Tx) error {
+		calls++
+		_, err := tx.ExecContext(ctx, update, 1, 184216)
+		return err
+	})
+	if !errors.Is(Classify(err), BDERRORUSEREXIST) || calls != 1 {
+		t.Errorf("bad error %v %d", err, calls)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+
+	// попытки кончились
+	for i := 0; i < 2; i++ {
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+	}
+	err = WithTx(ctx, db, &TxOptions{MaxAttempts: 2, Backoff: time.Millisecond}, func(tx *sql.Tx) error {
+		return &mysql.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded"}
+	})
+	if !errors.Is(Classify(err), BDERRORLOCKTIMEOUT) {
+		t.Errorf("bad error %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+
+	// паника откатывает транзакцию и пробрасывается дальше
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("panic lost")
+			}
+		}()
+		WithTx(ctx, db, nil, func(tx *sql.Tx) error {
+			panic("boom")
+		})
+	}()
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Querier работает внутри транзакции
+	mock.ExpectBegin()
+	mock.ExpectQueryRegexp("INFORMATION_SCHEMA").WithArgs("auth").WillReturnRows(
+		dbnamestest.NewRows("COLUMN_NAME", "COLUMN_TYPE", "IS_NULLABLE", "COLUMN_DEFAULT", "COLUMN_KEY", "EXTRA").
+			AddRow("user_id", "int(10) unsigned", "NO", nil, "PRI", ""))
+	mock.ExpectCommit()
+	err = WithTx(ctx, db, nil, func(tx *sql.Tx) error {
+		table, err := LoadTable(ctx, tx, "auth")
+		if err == nil && len(table.PrimaryKey) != 1 {
+			t.Errorf("bad table %+v", table)
+		}
+		return err
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInTx(t *testing.T) {
+	db, mock, err := dbnamestest.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	insert := "INSERT INTO `auth` (`user_id`) VALUES (?)"
+	failed := errors.New("failed")
+	mock.ExpectBegin()
+	mock.ExpectExec(insert).WithArgs(1)
+	mock.ExpectExec("SAVEPOINT `sp_1`")
+	mock.ExpectExec(insert).WithArgs(2)
+	mock.ExpectExec("RELEASE SAVEPOINT `sp_1`")
+	mock.ExpectExec("SAVEPOINT `sp_2`")
+	mock.ExpectExec(insert).WithArgs(3)
+	mock.ExpectExec("ROLLBACK TO SAVEPOINT `sp_2`")
+	mock.ExpectCommit()
+	err = InTx(context.Background(), db, nil, func(ctx context.Context, tx *Tx) error {
+		if _, err := tx.ExecContext(ctx, insert, 1); err != nil {
+			return err
+		}
+		err := InTx(ctx, db, nil, func(ctx context.Context, inner *Tx) error {
+			if inner != tx {
+				t.Errorf("inner scope got new transaction")
+			}
+			_, err := inner.ExecContext(ctx, insert, 2)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		err = InTx(ctx, db, nil, func(ctx context.Context, inner *Tx) error {
+			inner.ExecContext(ctx, insert, 3)
+			return failed
+		})
+		if err != failed {
+			t.Errorf("bad inner error %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+
+	// ошибка внешней области откатывает все
+	mock.ExpectBegin()
+	mock.ExpectExec(`SAVEPOINT "sp_1"`)
+	mock.ExpectExec(`RELEASE "sp_1"`)
+	mock.ExpectRollback()
+	err = InTx(context.Background(), db, &TxOptions{Dialect: DIALECTSQLITE}, func(ctx context.Context, tx *Tx) error {
+		if err := InTx(ctx, db, nil, func(ctx context.Context, tx *Tx) error { return nil }); err != nil {
+			return err
+		}
+		return failed
+	})
+	if err != failed {
+		t.Errorf("bad error %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+
+	// неудачный откат к точке сохранения не дает закоммитить транзакцию
+	broken := errors.New("broken")
+	mock.ExpectBegin()
+	mock.ExpectExec("SAVEPOINT `sp_1`")
+	mock.ExpectExec("ROLLBACK TO SAVEPOINT `sp_1`").WillReturnError(broken)
+	mock.ExpectRollback()
+	err = InTx(context.Background(), db, nil, func(ctx context.Context, tx *Tx) error {
+		err := InTx(ctx, db, nil, func(ctx context.Context, tx *Tx) error { return failed })
+		if !errors.Is(err, failed) || !errors.Is(err, broken) {
+			t.Errorf("bad nested error %v", err)
+		}
+		return nil
+	})
+	if !errors.Is(err, broken) {
+		t.Errorf("bad error %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}